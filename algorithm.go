@@ -0,0 +1,62 @@
+package ratata
+
+import "time"
+
+// Algorithm selects how a Limiter built by NewLimiter, or each user's limiter in a
+// RatataLimiter, decides whether an action is allowed; see WithAlgorithm.
+type Algorithm int
+
+const (
+	// TokenBucket lets a full bucket absorb a burst of up to its capacity, then
+	// admits one action per refill rate. It is the default.
+	TokenBucket Algorithm = iota
+	// GCRA, the generic cell rate algorithm, is a leaky bucket: actions are spaced
+	// one refill rate apart, with a burst tolerance of the capacity, tracked with a
+	// single timestamp. After a burst it admits actions at a steady pace instead of
+	// waiting for a whole bucket to refill.
+	GCRA
+	// SlidingWindow admits at most capacity actions in any window of capacity
+	// refill rates, keeping a log of the times of the actions in the window. It
+	// never allows more than capacity actions in a window, however they straddle
+	// its boundaries, at the cost of memory proportional to the capacity.
+	SlidingWindow
+)
+
+// String returns the name of the algorithm.
+func (a Algorithm) String() string {
+	switch a {
+	case TokenBucket:
+		return "token bucket"
+	case GCRA:
+		return "GCRA"
+	case SlidingWindow:
+		return "sliding window"
+	default:
+		return "unknown"
+	}
+}
+
+// NewLimiter returns a Limiter of capacity tokens, one earned per refillRate, that
+// uses the algorithm selected with WithAlgorithm, a RatataBucket by default. Callers
+// that only use the Limiter methods can switch algorithms by changing the option.
+func NewLimiter(capacity int, refillRate time.Duration, opts ...Option) Limiter {
+	o := newOptions(opts)
+	if o.algorithm == TokenBucket {
+		return NewRatataBucket(capacity, refillRate, opts...)
+	}
+	return newAlgorithmLimiter(capacity, refillRate, o)
+}
+
+// newAlgorithmLimiter builds a limiter for an algorithm other than TokenBucket, using
+// already-resolved options.
+func newAlgorithmLimiter(capacity int, refillRate time.Duration, o options) Limiter {
+	switch o.algorithm {
+	case GCRA:
+		return newGCRALimiter(capacity, refillRate, o)
+	case SlidingWindow:
+		window := time.Duration(capacity) * max(refillRate/o.perInterval, 1)
+		return newSlidingWindowLimiter(capacity, window, o)
+	default:
+		panic("ratata: unknown algorithm " + o.algorithm.String())
+	}
+}
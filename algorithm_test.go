@@ -0,0 +1,58 @@
+package ratata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewLimiterAlgorithms(t *testing.T) {
+	if _, ok := NewLimiter(1, time.Second).(*RatataBucket); !ok {
+		t.Error("NewLimiter doesn't default to a token bucket")
+	}
+	if _, ok := NewLimiter(1, time.Second, WithAlgorithm(GCRA)).(*GCRALimiter); !ok {
+		t.Error("WithAlgorithm(GCRA) doesn't build a GCRALimiter")
+	}
+	if _, ok := NewLimiter(1, time.Second, WithAlgorithm(SlidingWindow)).(*SlidingWindowLimiter); !ok {
+		t.Error("WithAlgorithm(SlidingWindow) doesn't build a SlidingWindowLimiter")
+	}
+}
+
+func TestAllowUserWithAlgorithm(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(2, time.Second, WithClock(clock), WithAlgorithm(GCRA))
+	if !rl.AllowUser("alice") || !rl.AllowUser("alice") || rl.AllowUser("alice") {
+		t.Error("want a burst of 2 and then a denial")
+	}
+	if _, ok := rl.lookupUser("alice").(*GCRALimiter); !ok {
+		t.Errorf("alice's limiter is a %T, want a *GCRALimiter", rl.lookupUser("alice"))
+	}
+	if err := rl.SetUserLimit("alice", 5, time.Second); err != nil {
+		t.Errorf("SetUserLimit on a GCRA user = %v", err)
+	}
+	if got := rl.AllowUserResult("bob").Limit; got != 2 {
+		t.Errorf("bob's Limit = %d, want 2", got)
+	}
+}
+
+func TestSetUserLimitTokensPerInterval(t *testing.T) {
+	for _, alg := range []Algorithm{GCRA, SlidingWindow} {
+		clock := newFakeClock()
+		rl := NewRatataLimiter(10, time.Second, WithClock(clock), WithAlgorithm(alg),
+			WithTokensPerInterval(10))
+		rl.AllowUser("u")
+		if err := rl.SetUserLimit("u", 10, time.Second); err != nil {
+			t.Fatalf("algorithm %v: SetUserLimit = %v", alg, err)
+		}
+		for rl.AllowUser("u") {
+		}
+		clock.Advance(time.Second)
+
+		allowed := 0
+		for rl.AllowUser("u") {
+			allowed++
+		}
+		if allowed != 10 {
+			t.Errorf("algorithm %v: %d allowed a second after draining, want 10", alg, allowed)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package ratata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllowDoesNotAllocate(t *testing.T) {
+	rb := NewRatataBucket(1<<30, time.Nanosecond)
+	rl := NewRatataLimiter(1<<30, time.Nanosecond)
+	denying := NewRatataLimiter(1, time.Hour, WithAutoBlock(1_000_000, time.Hour, time.Second))
+	configured := NewRatataLimiter(1<<30, time.Nanosecond, WithOnRecover(func(string) {}),
+		WithMaxUsers(10), WithRecentHistory(8), WithGlobalLimit(1<<30, time.Nanosecond))
+	for _, l := range []*RatataLimiter{rl, denying, configured} {
+		l.AllowUser("alice") // Create the user's bucket, which does allocate.
+	}
+
+	tests := []struct {
+		name string
+		f    func()
+	}{
+		{"RatataBucket.Allow", func() { rb.Allow() }},
+		{"RatataBucket.AllowN", func() { rb.AllowN(2) }},
+		{"RatataLimiter.Allow", func() { rl.Allow() }},
+		{"AllowUser", func() { rl.AllowUser("alice") }},
+		{"AllowUserResult", func() { rl.AllowUserResult("alice") }},
+		{"AllowUser denied", func() { denying.AllowUser("alice") }},
+		{"AllowUser with options", func() { configured.AllowUser("alice") }},
+		{"RetryAfterUser", func() { rl.RetryAfterUser("alice") }},
+	}
+	for _, tt := range tests {
+		if allocs := testing.AllocsPerRun(1000, tt.f); allocs != 0 {
+			t.Errorf("%s allocates %v times per call, want 0", tt.name, allocs)
+		}
+	}
+}
+
+func TestAllowUserDoesNotAllocateWhileBlocking(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(1, time.Hour, WithClock(clock),
+		WithAutoBlock(1_000_000, time.Millisecond, time.Second), WithGlobalLimit(1, time.Hour))
+	rl.AllowUser("alice")
+	rl.AllowUser("bob")
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		clock.Advance(time.Hour)
+		rl.AllowUser("alice")
+		rl.AllowUser("alice")
+	})
+	if allocs != 0 {
+		t.Errorf("AllowUser allocates %v times per refill and denial, want 0", allocs)
+	}
+}
@@ -0,0 +1,137 @@
+package ratata
+
+import "time"
+
+// denialWindow counts a user's denials within a fixed window, for auto-blocking.
+type denialWindow struct {
+	start time.Time // Start of the current window.
+	count int       // Denials seen since start.
+}
+
+// Block denies every action for userID until d has elapsed, regardless of the
+// tokens in the user's bucket. Blocking an already blocked user replaces the
+// remaining block time.
+func (rl *RatataLimiter) Block(userID string, d time.Duration) {
+	rl.block(rl.key(userID), d)
+}
+
+// block blocks the already normalized userID for d.
+func (rl *RatataLimiter) block(userID string, d time.Duration) {
+	rl.blockMu.Lock()
+	defer rl.blockMu.Unlock()
+
+	if rl.blocked == nil {
+		rl.blocked = make(map[string]time.Time)
+	}
+	if _, ok := rl.blocked[userID]; !ok {
+		rl.blockCount.Add(1)
+	}
+	rl.blocked[userID] = rl.opts.clock.Now().Add(d)
+	rl.opts.logger.Infof("ratata: blocked user %q for %s", userID, d)
+}
+
+// Unblock lifts a block placed on userID, if any.
+func (rl *RatataLimiter) Unblock(userID string) {
+	userID = rl.key(userID)
+	rl.blockMu.Lock()
+	defer rl.blockMu.Unlock()
+
+	rl.unblockLocked(userID)
+}
+
+// IsBlocked reports whether userID is currently blocked.
+func (rl *RatataLimiter) IsBlocked(userID string) bool {
+	return rl.blockedFor(rl.key(userID)) > 0
+}
+
+// blockedFor returns how long userID remains blocked, or zero if it isn't. Expired
+// blocks are removed as they are found.
+func (rl *RatataLimiter) blockedFor(userID string) time.Duration {
+	if rl.blockCount.Load() == 0 {
+		return 0 // Nobody is blocked; skip the lock on the hot path.
+	}
+
+	rl.blockMu.Lock()
+	defer rl.blockMu.Unlock()
+
+	until, ok := rl.blocked[userID]
+	if !ok {
+		return 0
+	}
+	remaining := until.Sub(rl.opts.clock.Now())
+	if remaining <= 0 {
+		rl.unblockLocked(userID)
+		return 0
+	}
+	return remaining
+}
+
+// unblockLocked removes userID from the blocklist. The caller must hold rl.blockMu.
+func (rl *RatataLimiter) unblockLocked(userID string) {
+	if _, ok := rl.blocked[userID]; ok {
+		delete(rl.blocked, userID)
+		rl.blockCount.Add(-1)
+		rl.opts.logger.Debugf("ratata: unblocked user %q", userID)
+	}
+}
+
+// noteDenial counts a denial for reason towards auto-blocking and blocks the user
+// once the threshold configured with WithAutoBlock is reached within the window.
+// Only ReasonNoTokens is counted: denials by the global limit or by SetDegraded are
+// caused by other users' traffic or by the operator, not by the user's own rate.
+func (rl *RatataLimiter) noteDenial(userID string, reason DenyReason) {
+	ab := rl.opts.autoBlock
+	if ab.threshold <= 0 || reason != ReasonNoTokens {
+		return
+	}
+	now := rl.opts.clock.Now()
+
+	rl.blockMu.Lock()
+	w := rl.denials[userID]
+	switch {
+	case w == nil:
+		if rl.denials == nil {
+			rl.denials = make(map[string]*denialWindow)
+		}
+		w = &denialWindow{start: now}
+		rl.denials[userID] = w
+	case now.Sub(w.start) >= ab.window:
+		*w = denialWindow{start: now} // Reuse the window rather than allocate a new one.
+	}
+	w.count++
+	trip := w.count >= ab.threshold
+	if trip {
+		delete(rl.denials, userID) // Start counting afresh once the block is lifted.
+	}
+	rl.blockMu.Unlock()
+
+	if trip {
+		rl.opts.logger.Infof("ratata: user %q reached %d denials within %s", userID, ab.threshold, ab.window)
+		rl.block(userID, ab.cooldown)
+	}
+}
+
+// forgetDenials drops the denials counted for userID, once the user is no longer
+// tracked. It takes rl.blockMu, so it may be called with a shard's mutex held.
+func (rl *RatataLimiter) forgetDenials(userID string) {
+	if rl.opts.autoBlock.threshold <= 0 {
+		return
+	}
+	rl.blockMu.Lock()
+	delete(rl.denials, userID)
+	rl.blockMu.Unlock()
+}
+
+// sweepDenials drops the denial windows that have expired as of now, for users that
+// were denied once and never again.
+func (rl *RatataLimiter) sweepDenials(now time.Time) {
+	window := rl.opts.autoBlock.window
+	rl.blockMu.Lock()
+	defer rl.blockMu.Unlock()
+
+	for id, w := range rl.denials {
+		if now.Sub(w.start) >= window {
+			delete(rl.denials, id)
+		}
+	}
+}
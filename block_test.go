@@ -0,0 +1,130 @@
+package ratata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAutoBlockIgnoresGlobalCap(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(100, time.Second, WithClock(clock),
+		WithGlobalLimit(1, time.Hour), WithAutoBlock(3, time.Minute, time.Hour))
+
+	if !rl.AllowUser("alice") {
+		t.Fatal("first action was denied")
+	}
+	for i := range 10 {
+		if ok, reason := rl.AllowUserReason("bob"); ok || reason != ReasonGlobalCap {
+			t.Fatalf("action %d = %v, %v; want denied by the global cap", i, ok, reason)
+		}
+	}
+	if rl.IsBlocked("bob") {
+		t.Error("bob was auto-blocked for denials caused by the global cap")
+	}
+}
+
+func TestAutoBlockIgnoresDegraded(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(10, time.Hour, WithClock(clock), WithAutoBlock(3, time.Minute, time.Hour))
+	rl.SetDegraded(0.5)
+
+	for range 5 {
+		rl.AllowUser("alice")
+	}
+	for i := range 10 {
+		if ok, reason := rl.AllowUserReason("alice"); ok || reason != ReasonDegraded {
+			t.Fatalf("action %d = %v, %v; want denied by SetDegraded", i, ok, reason)
+		}
+	}
+	if rl.IsBlocked("alice") {
+		t.Fatal("alice was auto-blocked for denials caused by SetDegraded")
+	}
+
+	rl.SetDegraded(1)
+	for range 5 {
+		rl.AllowUser("alice")
+	}
+	for range 3 {
+		rl.AllowUser("alice")
+	}
+	if !rl.IsBlocked("alice") {
+		t.Error("alice wasn't auto-blocked after 3 denials for an empty bucket")
+	}
+}
+
+func TestAutoBlockCooldown(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(1, time.Hour, WithClock(clock), WithAutoBlock(3, time.Minute, 10*time.Minute))
+
+	rl.AllowUser("alice")
+	rl.AllowUser("alice")
+	rl.AllowUser("alice")
+	if rl.IsBlocked("alice") {
+		t.Fatal("alice was blocked after 2 denials, before reaching the threshold")
+	}
+	rl.AllowUser("alice")
+	if !rl.IsBlocked("alice") {
+		t.Fatal("alice wasn't blocked after 3 denials")
+	}
+
+	clock.Advance(10 * time.Minute)
+	if rl.IsBlocked("alice") {
+		t.Fatal("alice is still blocked after the cooldown")
+	}
+	clock.Advance(time.Hour)
+	if !rl.AllowUser("alice") {
+		t.Error("alice was denied after the cooldown with a refilled bucket")
+	}
+}
+
+func TestAutoBlockForgetsDenials(t *testing.T) {
+	tests := []struct {
+		name   string
+		forget func(rl *RatataLimiter, clock *fakeClock)
+	}{
+		{"Remove", func(rl *RatataLimiter, clock *fakeClock) { rl.Remove("alice") }},
+		{"EvictIdle", func(rl *RatataLimiter, clock *fakeClock) {
+			clock.Advance(2 * time.Hour)
+			rl.EvictIdle(time.Hour)
+		}},
+		{"WithMaxUsers", func(rl *RatataLimiter, clock *fakeClock) {
+			clock.Advance(time.Second)
+			rl.AllowUser("bob")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			rl := NewRatataLimiter(1, time.Hour, WithClock(clock), WithMaxUsers(1),
+				WithAutoBlock(3, 24*time.Hour, time.Hour))
+			rl.AllowUser("alice")
+			rl.AllowUser("alice")
+			if len(rl.denials) != 1 {
+				t.Fatalf("got %d denial windows, want 1", len(rl.denials))
+			}
+
+			tt.forget(rl, clock)
+			if _, ok := rl.denials["alice"]; ok {
+				t.Error("alice's denials were kept after alice stopped being tracked")
+			}
+		})
+	}
+}
+
+func TestEvictIdleSweepsExpiredDenials(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(1, time.Hour, WithClock(clock), WithAutoBlock(3, time.Minute, time.Hour))
+	rl.AllowUser("alice")
+	rl.AllowUser("alice")
+
+	clock.Advance(30 * time.Second)
+	rl.EvictIdle(time.Hour)
+	if len(rl.denials) != 1 {
+		t.Fatalf("got %d denial windows within the window, want 1", len(rl.denials))
+	}
+	clock.Advance(30 * time.Second)
+	rl.EvictIdle(time.Hour)
+	if len(rl.denials) != 0 {
+		t.Errorf("got %d denial windows after the window expired, want 0", len(rl.denials))
+	}
+}
@@ -0,0 +1,74 @@
+package ratata
+
+import "math"
+
+// ChainLimiter is a Limiter that admits an action only if every one of its limiters
+// does, such as a per-client bucket and a bucket shared by a whole service. See
+// Chain.
+type ChainLimiter struct {
+	limiters []Limiter // Limiters consulted in order.
+}
+
+var _ Limiter = (*ChainLimiter)(nil)
+
+// Chain returns a Limiter that consumes from each of limiters in order and admits an
+// action only if all of them have the tokens. When one denies, the tokens already
+// taken from the limiters before it are returned, so a denial never leaves an
+// earlier level charged for an action that didn't happen. RatataBuckets, GCRA and
+// sliding window limiters and chains give tokens back; other limiters keep them.
+// Put the limiter most likely to deny first, typically the narrowest one. Each
+// limiter is locked in turn, not all at once, so a concurrent caller may briefly see
+// tokens that are about to be returned. An empty chain has no limit: it admits every
+// action and reports math.MaxInt tokens.
+//
+// For per-user buckets under one service-wide limit, WithGlobalLimit does the same
+// for every user of a RatataLimiter, and also shares the global budget fairly.
+func Chain(limiters ...Limiter) *ChainLimiter {
+	return &ChainLimiter{limiters: limiters}
+}
+
+// Allow consumes one token from every limiter if all of them have one.
+func (c *ChainLimiter) Allow() bool {
+	return c.AllowN(1)
+}
+
+// AllowN consumes n tokens from every limiter if all of them have n, returning the
+// tokens taken so far when one doesn't.
+func (c *ChainLimiter) AllowN(n int) bool {
+	for i, l := range c.limiters {
+		if !l.AllowN(n) {
+			for _, taken := range c.limiters[:i] {
+				refundLimiter(taken, n)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// Tokens returns the fewest tokens available in any of the limiters, the most an
+// action could take at once, or math.MaxInt for an empty chain, which is unlimited.
+func (c *ChainLimiter) Tokens() int {
+	tokens := math.MaxInt
+	for _, l := range c.limiters {
+		tokens = min(tokens, l.Tokens())
+	}
+	return tokens
+}
+
+// refund returns n tokens to every limiter of the chain.
+func (c *ChainLimiter) refund(n int) {
+	for _, l := range c.limiters {
+		refundLimiter(l, n)
+	}
+}
+
+// refundLimiter returns n tokens just taken from l, and reports whether l supports
+// it.
+func refundLimiter(l Limiter, n int) bool {
+	r, ok := l.(interface{ refund(n int) })
+	if ok && n > 0 {
+		r.refund(n)
+	}
+	return ok
+}
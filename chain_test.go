@@ -0,0 +1,55 @@
+package ratata
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestChainRefundsEarlierLimiters(t *testing.T) {
+	clock := newFakeClock()
+	user := NewRatataBucket(3, time.Second, WithClock(clock))
+	gcra := NewGCRALimiter(3, time.Second, WithClock(clock))
+	window := NewSlidingWindowLimiter(3, time.Second, WithClock(clock))
+	global := NewRatataBucket(2, time.Second, WithClock(clock))
+	chain := Chain(user, gcra, window, global)
+
+	if !chain.AllowN(2) {
+		t.Fatal("AllowN(2) was denied with 2 tokens at every level")
+	}
+	if chain.Allow() {
+		t.Fatal("Allow was admitted with the global bucket empty")
+	}
+	for name, l := range map[string]Limiter{"user": user, "gcra": gcra, "window": window} {
+		if got := l.Tokens(); got != 1 {
+			t.Errorf("%s has %d tokens, want 1: the denied action wasn't refunded", name, got)
+		}
+	}
+	if got := chain.Tokens(); got != 0 {
+		t.Errorf("chain has %d tokens, want 0", got)
+	}
+}
+
+func TestEmptyChainIsUnlimited(t *testing.T) {
+	chain := Chain()
+	if !chain.AllowN(1000) {
+		t.Error("an empty chain denied an action")
+	}
+	if got := chain.Tokens(); got != math.MaxInt {
+		t.Errorf("an empty chain has %d tokens, want math.MaxInt", got)
+	}
+}
+
+func TestGlobalLimitRefundsAlgorithmLimiters(t *testing.T) {
+	rl := NewRatataLimiter(5, time.Second, WithAlgorithm(GCRA), WithGlobalLimit(1, time.Hour))
+	if !rl.AllowUser("alice") {
+		t.Fatal("first action was denied")
+	}
+	res := rl.AllowUserResult("bob")
+	if res.Allowed || res.Reason != ReasonGlobalCap {
+		t.Fatalf("bob: allowed %v, reason %v; want denied by the global cap", res.Allowed, res.Reason)
+	}
+	if got := rl.lookupUser("bob").Tokens(); got != 5 {
+		t.Errorf("bob has %d tokens, want 5: the global denial wasn't refunded", got)
+	}
+}
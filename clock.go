@@ -0,0 +1,23 @@
+package ratata
+
+import "time"
+
+// Clock is the source of time used by a RatataBucket. It lets tests and
+// simulations control time instead of depending on the wall clock.
+type Clock interface {
+	Now() time.Time                         // Now returns the current time.
+	After(d time.Duration) <-chan time.Time // After sends the current time once d has elapsed.
+}
+
+// systemClock is the default Clock, backed by the time package.
+type systemClock struct{}
+
+// Now returns the current wall-clock time.
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// After waits for d to elapse on the wall clock and then sends the current time.
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
@@ -0,0 +1,78 @@
+package ratata
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// fakeClock is a Clock for tests that only moves when Advance is called.
+type fakeClock struct {
+	mu      sync.Mutex   // Mutex to protect the fields below.
+	now     time.Time    // Current fake time.
+	waiters []fakeWaiter // Pending After calls, in the order they were made.
+}
+
+// fakeWaiter is a pending After call on a fakeClock.
+type fakeWaiter struct {
+	at time.Time      // Time at which ch fires.
+	ch chan time.Time // Channel returned by After.
+}
+
+// newFakeClock returns a fakeClock set to a fixed, arbitrary time.
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1_000_000, 0)}
+}
+
+// Now returns the current fake time.
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel that fires once the clock has been advanced by d.
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward by d and fires every After that is now due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	keep := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			keep = append(keep, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = keep
+}
+
+// Waiters returns the number of After calls that haven't fired yet.
+func (c *fakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// BlockUntil waits until n After calls are pending, so a test can advance the clock
+// only once its goroutines are asleep on it.
+func (c *fakeClock) BlockUntil(n int) {
+	for c.Waiters() < n {
+		runtime.Gosched()
+	}
+}
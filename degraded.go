@@ -0,0 +1,42 @@
+package ratata
+
+import "math"
+
+// SetDegraded scales the tokens every user may consume by factor, an emergency knob
+// for shedding load during an incident without touching each user's config. With a
+// factor of 0.5, a user can only use half of their bucket, rounded to the nearest
+// token, and the other half is held back until the factor is raised again; a factor
+// of 0 denies everyone and 1 restores normal capacity. The factor applies to
+// AllowUser and to the global bucket behind Allow, takes effect immediately and is
+// safe to change concurrently with admission checks.
+//
+// Held-back tokens keep refilling, so the factor sheds bursts rather than slowing
+// sustained traffic; combine it with SetUserRateMultiplier to lower the rate as well.
+// Limiters built by a bucket factory and buckets in a Store are not degraded.
+// Negative factors are ignored, and factors above 1 count as 1.
+func (rl *RatataLimiter) SetDegraded(factor float64) {
+	if factor < 0 {
+		return
+	}
+	rl.degraded.Store(math.Float64bits(min(factor, 1)))
+	rl.opts.logger.Infof("ratata: set degraded factor to %g", min(factor, 1))
+}
+
+// degradedFactor returns the factor set with SetDegraded.
+func (rl *RatataLimiter) degradedFactor() float64 {
+	return math.Float64frombits(rl.degraded.Load())
+}
+
+// globalHeld returns the tokens of the global bucket held back by SetDegraded.
+func (rl *RatataLimiter) globalHeld() int {
+	return heldFor(rl.capacity, rl.degradedFactor())
+}
+
+// heldFor returns how many of available tokens to hold back so that only factor of
+// them can be used.
+func heldFor(available int, factor float64) int {
+	if factor >= 1 {
+		return 0
+	}
+	return available - int(math.Round(float64(available)*factor))
+}
@@ -0,0 +1,48 @@
+package ratata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetDegraded(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(10, time.Second, WithClock(clock))
+
+	// admit reports how many of 20 actions AllowUser and Allow each admit.
+	admit := func() (user, global int) {
+		for range 20 {
+			if rl.AllowUser("alice") {
+				user++
+			}
+			if rl.Allow() {
+				global++
+			}
+		}
+		return user, global
+	}
+
+	rl.SetDegraded(0.5)
+	if user, global := admit(); user != 5 || global != 5 {
+		t.Errorf("at factor 0.5 admitted %d user and %d global actions, want 5 each", user, global)
+	}
+	if res := rl.AllowUserResult("alice"); res.Remaining != 0 || res.RetryAfter != time.Second {
+		t.Errorf("at factor 0.5 Remaining %d, RetryAfter %v; want 0, 1s", res.Remaining, res.RetryAfter)
+	}
+
+	// The tokens left over are usable again at once, without touching the buckets.
+	rl.SetDegraded(1)
+	if user, global := admit(); user != 5 || global != 5 {
+		t.Errorf("back at factor 1 admitted %d user and %d global actions, want the other 5 each", user, global)
+	}
+	clock.Advance(10 * time.Second)
+	if user, _ := admit(); user != 10 {
+		t.Errorf("after a full refill admitted %d user actions, want the capacity of 10", user)
+	}
+
+	rl.SetDegraded(0)
+	clock.Advance(10 * time.Second)
+	if rl.AllowUser("alice") || rl.Allow() || rl.Tokens() != 0 {
+		t.Error("factor 0 admitted an action")
+	}
+}
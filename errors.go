@@ -0,0 +1,30 @@
+package ratata
+
+import "errors"
+
+var (
+	// ErrInvalidCapacity is returned when a bucket is configured with a negative capacity.
+	ErrInvalidCapacity = errors.New("ratata: capacity must not be negative")
+
+	// ErrInvalidRefillRate is returned when a bucket is configured with a non-positive refill rate.
+	ErrInvalidRefillRate = errors.New("ratata: refill rate must be positive")
+
+	// ErrInvalidRateMultiplier is returned when a bucket is configured with a negative rate multiplier.
+	ErrInvalidRateMultiplier = errors.New("ratata: rate multiplier must not be negative")
+
+	// ErrExceedsCapacity is returned when a request needs more tokens than the bucket can ever hold.
+	ErrExceedsCapacity = errors.New("ratata: requested tokens exceed bucket capacity")
+
+	// ErrWaitAttemptsExceeded is returned by Wait when it gives up after the number of
+	// re-checks configured with WithWaitMaxAttempts.
+	ErrWaitAttemptsExceeded = errors.New("ratata: wait attempts exceeded")
+
+	// ErrInvalidTokenCount is returned when an operation is asked to move a negative number of tokens.
+	ErrInvalidTokenCount = errors.New("ratata: token count must not be negative")
+
+	// ErrUnsupportedSnapshot is returned when a Snapshot has a format version this package doesn't know.
+	ErrUnsupportedSnapshot = errors.New("ratata: unsupported snapshot version")
+
+	// ErrSameUser is returned when tokens are transferred from a user to itself.
+	ErrSameUser = errors.New("ratata: cannot transfer tokens to the same user")
+)
@@ -0,0 +1,36 @@
+package ratata
+
+import (
+	"math"
+	"time"
+)
+
+// Never is returned by TimeToEmpty for a bucket that refill keeps from ever running out.
+const Never time.Duration = math.MaxInt64
+
+// TimeToEmpty estimates how long the bucket's available tokens would last if they
+// were consumed at ratePerSecond tokens a second from now on, with refill offsetting
+// the consumption, so clients can pace themselves. It returns zero if no token is
+// available now, and Never if refill keeps up with the consumption. The estimate
+// treats refill as continuous, so it may be off by up to one refill interval.
+func (rb *RatataBucket) TimeToEmpty(ratePerSecond float64) time.Duration {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.refillRatata(rb.opts.clock.Now())
+	available := rb.tokens - rb.opts.reserve
+	if available <= 0 {
+		return 0
+	}
+
+	refillPerSecond := float64(rb.opts.perInterval) * float64(time.Second) / float64(rb.refillRate)
+	net := ratePerSecond - refillPerSecond
+	if net <= 0 {
+		return Never
+	}
+	d := float64(available) / net * float64(time.Second)
+	if d >= float64(Never) {
+		return Never
+	}
+	return time.Duration(d)
+}
@@ -0,0 +1,29 @@
+package ratata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeToEmpty(t *testing.T) {
+	b := NewRatataBucket(100, 100*time.Millisecond) // Refills 10 tokens a second.
+
+	tests := []struct {
+		rate float64
+		want time.Duration
+	}{
+		{20, 10 * time.Second}, // 100 tokens at a net 10 a second.
+		{10, Never},            // Refill exactly keeps up.
+		{5, Never},
+	}
+	for _, tt := range tests {
+		if got := b.TimeToEmpty(tt.rate); got != tt.want {
+			t.Errorf("TimeToEmpty(%v) = %v, want %v", tt.rate, got, tt.want)
+		}
+	}
+
+	b.AllowN(100)
+	if got := b.TimeToEmpty(20); got != 0 {
+		t.Errorf("TimeToEmpty of an empty bucket = %v, want 0", got)
+	}
+}
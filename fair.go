@@ -0,0 +1,98 @@
+package ratata
+
+import (
+	"sync"
+	"time"
+)
+
+// globalLimit is a limit shared by all users of a RatataLimiter on top of their
+// own buckets. When it is the binding constraint, its budget is split fairly among
+// the users competing for it instead of going to whoever asks first.
+//
+// Fairness is max-min over fixed windows, each as long as it takes to refill the
+// global bucket completely. Within a window every user that asks for a token counts
+// as active, and while the global bucket is below half full each active user may
+// take at most an equal share of a window's refill. A user that demands less leaves
+// its share to others, since the bucket then stays above half full and tokens are
+// handed out first come, first served.
+type globalLimit struct {
+	bucket      *RatataBucket  // Global token bucket.
+	window      time.Duration  // Length of a fairness window.
+	windowStart time.Time      // Start of the current window.
+	used        map[string]int // Global tokens granted per active user in the window.
+	prevActive  int            // Number of active users in the previous window.
+	mu          sync.Mutex     // Mutex to protect the fairness state.
+}
+
+// newGlobalLimit returns a full global limit as of now. Its bucket shares only the
+// clock, refill interval and rounding of the per-user buckets, so options such as
+// WithOverdraft, WithReserve or WithWelcomeBurst never loosen the global cap.
+func newGlobalLimit(capacity int, refillRate time.Duration, o options) *globalLimit {
+	bo := defaultOptions()
+	bo.clock, bo.perInterval, bo.rounding = o.clock, o.perInterval, o.rounding
+	return &globalLimit{
+		bucket:      newBucket(capacity, refillRate, bo),
+		window:      time.Duration(capacity) * refillRate / o.perInterval,
+		windowStart: o.clock.Now(),
+		used:        make(map[string]int),
+	}
+}
+
+// take consumes n global tokens for userID as of now if the bucket has them and the
+// user is within its fair share. Otherwise it returns how long until it is worth
+// trying again.
+func (g *globalLimit) take(userID string, n int, now time.Time) (bool, time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if now.Sub(g.windowStart) >= g.window {
+		g.prevActive = len(g.used)
+		clear(g.used) // Keep the map's storage for the next window.
+		g.windowStart = now
+	}
+	used, seen := g.used[userID]
+	if !seen {
+		g.used[userID] = 0 // The user is now active in this window.
+	}
+
+	b := g.bucket
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refillRatata(now)
+	if b.tokens*2 < b.capacity {
+		// The global bucket is contended: hold every user to an equal share. The
+		// previous window's count keeps early arrivals from taking too much.
+		active := max(len(g.used), g.prevActive)
+		share := (b.capacity + active - 1) / active
+		if used >= share {
+			return false, g.windowStart.Add(g.window).Sub(now)
+		}
+	}
+	if !b.allowNRatata(now, n) {
+		return false, b.delayRatata(now, b.opts.reserve+n)
+	}
+	g.used[userID] = used + n
+	return true, 0
+}
+
+// applyGlobal enforces the global limit on a decision for n tokens that userID's own
+// limiter allowed, denying it if the global limit does and returning the user's
+// tokens if the limiter can take them back; see Chain.
+func (rl *RatataLimiter) applyGlobal(userID string, l Limiter, n int, res Result) Result {
+	if rl.globalLimit == nil || !res.Allowed {
+		return res
+	}
+	ok, retryAfter := rl.globalLimit.take(userID, n, rl.opts.clock.Now())
+	if ok {
+		return res
+	}
+
+	if refundLimiter(l, n) {
+		res.Remaining += n // Don't charge the user for an action that didn't happen.
+	}
+	res.Allowed = false
+	res.Reason = ReasonGlobalCap
+	res.RetryAfter = max(res.RetryAfter, retryAfter)
+	return res
+}
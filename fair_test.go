@@ -0,0 +1,84 @@
+package ratata
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestGlobalLimitSharesFairly(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(100, time.Millisecond, WithClock(clock), WithGlobalLimit(10, 100*time.Millisecond))
+
+	ids := []string{"noisy", "u1", "u2", "u3", "u4"}
+	counts := make(map[string]int)
+	for range 20000 {
+		clock.Advance(10 * time.Millisecond)
+		for range 10 { // The noisy user asks ten times as often as the others.
+			if rl.AllowUser(ids[0]) {
+				counts[ids[0]]++
+			}
+		}
+		for _, id := range ids[1:] {
+			if rl.AllowUser(id) {
+				counts[id]++
+			}
+		}
+	}
+
+	var total int
+	for _, n := range counts {
+		total += n
+	}
+	share := total / len(ids)
+	for _, id := range ids {
+		if n := counts[id]; n < share*8/10 || n > share*12/10 {
+			t.Errorf("user %s was admitted %d times, want about %d of %d", id, n, share, total)
+		}
+	}
+	if got := rl.userLimiter("u1").Tokens(); got < 90 {
+		t.Errorf("u1 has %d tokens, want its denied tokens refunded", got)
+	}
+}
+
+func TestGlobalLimitSingleUserGetsAll(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(100, time.Millisecond, WithClock(clock), WithGlobalLimit(10, time.Second))
+
+	var admitted int
+	for range 100 {
+		if rl.AllowUser("alone") {
+			admitted++
+		}
+	}
+	if admitted != 10 {
+		t.Errorf("a lone user was admitted %d times, want the whole global capacity of 10", admitted)
+	}
+}
+
+func TestGlobalLimitIgnoresBucketOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  Option
+	}{
+		{"overdraft", WithOverdraft()},
+		{"welcome burst", WithWelcomeBurst(50)},
+		{"reserve", WithReserve(5)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := NewRatataLimiter(10, time.Hour, WithClock(newFakeClock()), WithGlobalLimit(5, time.Hour), tt.opt)
+			var admitted int
+			for i := range 10 {
+				for range 10 {
+					if rl.AllowUser(fmt.Sprint("user-", i)) {
+						admitted++
+					}
+				}
+			}
+			if admitted != 5 {
+				t.Errorf("admitted %d actions, want the global capacity of 5", admitted)
+			}
+		})
+	}
+}
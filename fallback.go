@@ -0,0 +1,27 @@
+package ratata
+
+import "context"
+
+// AllowWithFallback consumes a token from primaryKey's bucket if it has one and
+// otherwise from fallbackKey's, modelling a soft per-endpoint limit backed by a
+// shared per-user budget. It returns the key whose bucket served the request, or
+// false if both were exhausted. The attempt counts as a single decision in the
+// limiter's stats, and exhausting only the primary doesn't count towards
+// auto-blocking.
+func (rl *RatataLimiter) AllowWithFallback(primaryKey, fallbackKey string) (servedBy string, allowed bool) {
+	primaryKey, fallbackKey = rl.key(primaryKey), rl.key(fallbackKey)
+	if res, _, _ := rl.evaluate(context.Background(), primaryKey, 1); res.Allowed {
+		rl.recordUser(res, 1)
+		return primaryKey, true
+	}
+
+	res, blocked, err := rl.evaluate(context.Background(), fallbackKey, 1)
+	rl.recordUser(res, 1)
+	if !res.Allowed {
+		if !blocked && err == nil {
+			rl.noteDenial(fallbackKey, res.Reason)
+		}
+		return "", false
+	}
+	return fallbackKey, true
+}
@@ -0,0 +1,29 @@
+package ratata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllowWithFallback(t *testing.T) {
+	rl := NewRatataLimiter(1, time.Hour)
+
+	tests := []struct {
+		name        string
+		wantServed  string
+		wantAllowed bool
+	}{
+		{"primary hit", "search", true},
+		{"fallback hit", "alice", true},
+		{"both exhausted", "", false},
+	}
+	for _, tt := range tests {
+		served, allowed := rl.AllowWithFallback("search", "alice")
+		if served != tt.wantServed || allowed != tt.wantAllowed {
+			t.Errorf("%s: got %q, %v; want %q, %v", tt.name, served, allowed, tt.wantServed, tt.wantAllowed)
+		}
+	}
+	if s := rl.Stats(); s.Allowed != 2 || s.Denied != 1 {
+		t.Errorf("stats: %d allowed, %d denied; want each attempt counted once: 2 and 1", s.Allowed, s.Denied)
+	}
+}
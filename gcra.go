@@ -0,0 +1,142 @@
+package ratata
+
+import (
+	"sync"
+	"time"
+)
+
+// GCRALimiter is a Limiter implementing the generic cell rate algorithm, a leaky
+// bucket that spaces actions one interval apart and tolerates bursts of up to its
+// capacity. Rather than a token count it keeps the theoretical arrival time, the
+// time at which it would have drained all the actions admitted so far.
+type GCRALimiter struct {
+	capacity    int           // Maximum burst of actions.
+	interval    time.Duration // Spacing of actions at the sustained rate.
+	perInterval time.Duration // Actions sharing each interval given to SetLimit.
+	tat         time.Time     // Theoretical arrival time; the limiter is idle once it has passed.
+	clock       Clock         // Source of time.
+	mu          sync.Mutex    // Mutex to protect the fields above.
+}
+
+var _ Limiter = (*GCRALimiter)(nil)
+
+// NewGCRALimiter returns a GCRALimiter that admits bursts of up to capacity actions
+// and one action per interval after that. Of the options, only WithClock and
+// WithTokensPerInterval apply.
+func NewGCRALimiter(capacity int, interval time.Duration, opts ...Option) *GCRALimiter {
+	return newGCRALimiter(capacity, interval, newOptions(opts))
+}
+
+// newGCRALimiter returns an idle GCRALimiter using already-resolved options.
+func newGCRALimiter(capacity int, interval time.Duration, o options) *GCRALimiter {
+	return &GCRALimiter{
+		capacity:    capacity,
+		interval:    max(interval/o.perInterval, 1),
+		perInterval: o.perInterval,
+		clock:       o.clock,
+	}
+}
+
+// Allow admits one action if the rate allows it.
+func (g *GCRALimiter) Allow() bool {
+	return g.AllowN(1)
+}
+
+// AllowN admits n actions at once if the rate allows all of them. More actions than
+// the capacity are never admitted.
+func (g *GCRALimiter) AllowN(n int) bool {
+	if n <= 0 {
+		return true
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if n > g.capacity {
+		return false // Can never fit, and would overflow the arrival time below.
+	}
+	now := g.clock.Now()
+	tat := later(g.tat, now).Add(time.Duration(n) * g.interval)
+	if tat.Sub(now) > time.Duration(g.capacity)*g.interval {
+		return false
+	}
+	g.tat = tat
+	return true
+}
+
+// Tokens returns the number of actions that could be admitted at once right now.
+func (g *GCRALimiter) Tokens() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.tokensLocked(g.clock.Now())
+}
+
+// Capacity returns the maximum burst of actions.
+func (g *GCRALimiter) Capacity() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.capacity
+}
+
+// RetryAfter returns how long until one action could be admitted, zero if it could be
+// now, or Never if the capacity is zero.
+func (g *GCRALimiter) RetryAfter() time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.capacity <= 0 {
+		return Never
+	}
+	now := g.clock.Now()
+	return max(g.tat.Sub(now)-time.Duration(g.capacity-1)*g.interval, 0)
+}
+
+// SetLimit changes the limiter's capacity and interval, which
+// RatataLimiter.SetUserLimit relies on. As in NewGCRALimiter, the interval is shared
+// by the actions per interval set with WithTokensPerInterval. The actions still being
+// drained are kept, as a count, and drain at the new interval, up to the new
+// capacity. It returns an error, and changes nothing, if the configuration is
+// invalid.
+func (g *GCRALimiter) SetLimit(capacity int, interval time.Duration) error {
+	if err := validateConfig(capacity, interval); err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.clock.Now()
+	pending := min(g.capacity-g.tokensLocked(now), capacity)
+	interval = max(interval/g.perInterval, 1)
+	g.capacity, g.interval = capacity, interval
+	g.tat = now.Add(time.Duration(pending) * interval)
+	return nil
+}
+
+// refund gives back n actions just admitted, at most the capacity.
+func (g *GCRALimiter) refund(n int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.tat = g.tat.Add(-time.Duration(min(n, g.capacity)) * g.interval)
+}
+
+// tokensLocked returns the number of actions that could be admitted at once as of
+// now. The caller must hold g.mu.
+func (g *GCRALimiter) tokensLocked(now time.Time) int {
+	backlog := later(g.tat, now).Sub(now)
+	if backlog <= 0 {
+		return max(g.capacity, 0)
+	}
+	return max(g.capacity-int((backlog+g.interval-1)/g.interval), 0)
+}
+
+// later returns the later of a and b.
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
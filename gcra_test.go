@@ -0,0 +1,62 @@
+package ratata
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGCRA(t *testing.T) {
+	clock := newFakeClock()
+	l := NewGCRALimiter(3, time.Second, WithClock(clock))
+	if l.Tokens() != 3 || !l.AllowN(3) || l.Allow() || l.Tokens() != 0 {
+		t.Fatalf("want a burst of 3 and then nothing, with %d tokens left", l.Tokens())
+	}
+	if got := l.RetryAfter(); got != time.Second {
+		t.Errorf("RetryAfter = %v, want 1s", got)
+	}
+
+	// Actions are spaced evenly, one per second, with no partial credit.
+	clock.Advance(500 * time.Millisecond)
+	if l.Allow() || l.Tokens() != 0 {
+		t.Error("admitted an action half a second early")
+	}
+	clock.Advance(500 * time.Millisecond)
+	if !l.Allow() || l.Allow() {
+		t.Error("want exactly one action after a second")
+	}
+
+	clock.Advance(10 * time.Second)
+	if l.Tokens() != 3 || l.AllowN(4) {
+		t.Errorf("an idle limiter has %d tokens, want the burst of 3 and no more", l.Tokens())
+	}
+	l.AllowN(2)
+	l.SetLimit(10, time.Second)
+	if got := l.Tokens(); got != 8 {
+		t.Errorf("Tokens after raising the burst to 10 = %d, want 8", got)
+	}
+}
+
+func TestGCRAZeroBurst(t *testing.T) {
+	l := NewGCRALimiter(0, time.Second)
+	if l.Allow() || l.Tokens() != 0 || l.RetryAfter() != Never {
+		t.Error("a GCRA limiter with a burst of zero admitted an action")
+	}
+}
+
+func TestGCRAHugeCount(t *testing.T) {
+	l := NewGCRALimiter(10, time.Second, WithClock(newFakeClock()))
+	for _, n := range []int{11, math.MaxInt64/2 + 1, math.MaxInt} {
+		if l.AllowN(n) {
+			t.Errorf("AllowN(%d) was allowed by a burst of 10", n)
+		}
+	}
+	l.AllowN(2)
+	l.refund(math.MaxInt)
+	if got := l.Tokens(); got != 10 {
+		t.Errorf("Tokens = %d after a huge refund, want the burst of 10", got)
+	}
+	if !l.AllowN(10) || l.Allow() {
+		t.Error("a huge refund let more than the burst through")
+	}
+}
@@ -0,0 +1,93 @@
+package ratata
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// atomicBucket is a lock-free token bucket. Instead of a token count and a refill
+// time it stores a single theoretical arrival time (the GCRA formulation): the
+// moment at which the bucket would be back to full if nothing else were consumed.
+// Each admission pushes that moment one interval further, and a request is denied
+// if doing so would put it beyond the bucket's capacity worth of intervals. This
+// admits exactly what a token bucket does, with a single compare-and-swap.
+type atomicBucket struct {
+	tat      atomic.Int64 // Theoretical arrival time, in nanoseconds since epoch.
+	capacity int64        // Maximum number of tokens.
+	interval int64        // Nanoseconds to earn one token.
+	burst    int64        // Nanoseconds to earn a full bucket.
+	epoch    time.Time    // Time that tat is measured from.
+}
+
+// newAtomicBucket returns a full atomic bucket as of epoch.
+func newAtomicBucket(capacity int, refillRate time.Duration, epoch time.Time) *atomicBucket {
+	return &atomicBucket{
+		capacity: int64(capacity),
+		interval: int64(refillRate),
+		burst:    int64(capacity) * int64(refillRate),
+		epoch:    epoch,
+	}
+}
+
+// allowN consumes n tokens as of now if they are available, keeping held tokens
+// out of reach.
+func (ab *atomicBucket) allowN(now time.Time, n, held int) bool {
+	if ab.burst <= 0 {
+		return false // A zero-capacity bucket denies everything.
+	}
+	if int64(n) > ab.capacity-int64(held) {
+		return false // Can never fit, and would overflow the cost below.
+	}
+	t := int64(now.Sub(ab.epoch))
+	cost := int64(n) * ab.interval
+	limit := ab.burst - int64(held)*ab.interval
+	for {
+		tat := ab.tat.Load()
+		next := max(tat, t) + cost
+		if next-t > limit {
+			return false // Would exceed capacity.
+		}
+		if ab.tat.CompareAndSwap(tat, next) {
+			return true
+		}
+	}
+}
+
+// tokens returns the number of tokens available as of now, not counting held tokens.
+func (ab *atomicBucket) tokens(now time.Time, held int) int {
+	if ab.burst <= 0 {
+		return 0
+	}
+	t := int64(now.Sub(ab.epoch))
+	debt := max(ab.tat.Load(), t) - t
+	return max(int((ab.burst-debt)/ab.interval)-held, 0)
+}
+
+// Allow checks if an action is allowed against the limiter's global bucket, which
+// has the limiter's capacity and refill rate and is shared by all callers regardless
+// of user. It is lock-free and allocation-free, and a limiter used only through
+// Allow and AllowN never allocates per-user state, making it the cheapest way to
+// cap total throughput, e.g. to protect an ingress.
+//
+// Prefer the global path when only aggregate load matters; use AllowUser when each
+// user needs their own budget. The global bucket always refills lazily with floor
+// rounding, and bucket options such as WithReserve or WithOverdraft don't apply.
+func (rl *RatataLimiter) Allow() bool {
+	return rl.AllowN(1)
+}
+
+// AllowN checks if n tokens are available in the limiter's global bucket and
+// consumes them all if so. See Allow.
+func (rl *RatataLimiter) AllowN(n int) bool {
+	res := Result{Allowed: n <= 0 || rl.global.allowN(rl.opts.clock.Now(), n, rl.globalHeld())}
+	if !res.Allowed {
+		res.Reason = ReasonNoTokens
+	}
+	rl.record(res, n)
+	return res.Allowed
+}
+
+// Tokens returns the number of tokens available in the limiter's global bucket.
+func (rl *RatataLimiter) Tokens() int {
+	return rl.global.tokens(rl.opts.clock.Now(), rl.globalHeld())
+}
@@ -0,0 +1,75 @@
+package ratata
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAllowAggregateAdmission(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(100, time.Second, WithClock(clock))
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				if rl.Allow() {
+					allowed.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if got := allowed.Load(); got != 100 {
+		t.Errorf("Allow admitted %d of 500 calls, want 100", got)
+	}
+	if rl.Len() != 0 || rl.Tokens() != 0 {
+		t.Errorf("got %d users and %d tokens, want none", rl.Len(), rl.Tokens())
+	}
+
+	clock.Advance(2500 * time.Millisecond)
+	if got := rl.Tokens(); got != 2 {
+		t.Fatalf("Tokens after 2.5s = %d, want 2", got)
+	}
+	if !rl.AllowN(2) || rl.Allow() {
+		t.Error("want the 2 refilled tokens and no more")
+	}
+	if s := rl.Stats(); s.Allowed != 101 || s.Denied != 401 {
+		t.Errorf("Stats = %d allowed, %d denied; want 101, 401", s.Allowed, s.Denied)
+	}
+}
+
+func TestAllowNHugeCount(t *testing.T) {
+	rl := NewRatataLimiter(10, time.Second, WithClock(newFakeClock()))
+	for _, n := range []int{11, 1 << 62, math.MaxInt} {
+		if rl.AllowN(n) {
+			t.Errorf("AllowN(%d) was allowed by a 10-token bucket", n)
+		}
+	}
+	rl.SetDegraded(0.5)
+	if rl.AllowN(6) {
+		t.Error("AllowN(6) was allowed with 5 of 10 tokens held back")
+	}
+	if got := rl.Tokens(); got != 5 {
+		t.Errorf("Tokens = %d after the denials, want 5", got)
+	}
+}
+
+// BenchmarkAllowParallel measures the global Allow from concurrent goroutines; run it
+// with -cpu 1,2,4,8 to see how it behaves under contention.
+func BenchmarkAllowParallel(b *testing.B) {
+	rl := NewRatataLimiter(1<<30, time.Nanosecond)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			rl.Allow()
+		}
+	})
+}
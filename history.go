@@ -0,0 +1,109 @@
+package ratata
+
+import (
+	"sync"
+	"time"
+)
+
+// Decision is a single admission decision made by a RatataLimiter.
+type Decision struct {
+	Time    time.Time // Time the decision was made.
+	Key     string    // Key the decision was made for, such as a user ID.
+	Allowed bool      // Whether the action was allowed.
+}
+
+// ring is a fixed-size buffer of the most recent entries, such as decisions.
+type ring[T any] struct {
+	entries []T        // Ring storage; next is the slot to overwrite.
+	next    int        // Index of the next slot to write.
+	full    bool       // Whether every slot has been written at least once.
+	mu      sync.Mutex // Mutex to protect the ring.
+}
+
+// newRing returns a ring holding up to n entries.
+func newRing[T any](n int) *ring[T] {
+	return &ring[T]{entries: make([]T, n)}
+}
+
+// add stores v, overwriting the oldest entry if the ring is full.
+func (r *ring[T]) add(v T) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries[r.next] = v
+	r.next++
+	if r.next == len(r.entries) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// list returns the stored entries from oldest to newest.
+func (r *ring[T]) list() []T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.full {
+		return append([]T(nil), r.entries[:r.next]...)
+	}
+	out := make([]T, 0, len(r.entries))
+	out = append(out, r.entries[r.next:]...)
+	return append(out, r.entries[:r.next]...)
+}
+
+// RecentDecisions returns the decisions kept by WithRecentHistory, oldest first.
+// It returns nil if the history isn't enabled.
+func (rl *RatataLimiter) RecentDecisions() []Decision {
+	if rl.history == nil {
+		return nil
+	}
+	return rl.history.list()
+}
+
+// UserAuditLog returns the times of userID's most recent allowed actions, oldest
+// first, as kept by WithAuditHistory. It returns nil if the audit history isn't
+// enabled or the user has no allowed actions on record.
+func (rl *RatataLimiter) UserAuditLog(userID string) []time.Time {
+	userID = rl.key(userID)
+
+	s := rl.shard(userID)
+	var audit *ring[time.Time]
+	s.mu.Lock()
+	if e, ok := s.users[userID]; ok {
+		audit = e.audit
+	}
+	s.mu.Unlock()
+	if audit == nil {
+		return nil
+	}
+	return audit.list()
+}
+
+// recordUser records a per-user decision for n tokens like record, counts it in the
+// user's shard, and adds an allowed one to the user's audit history.
+func (rl *RatataLimiter) recordUser(res Result, n int) {
+	rl.record(res, n)
+	s := rl.shard(res.Key)
+	if !res.Allowed {
+		s.denied.Add(1)
+		return
+	}
+	s.allowed.Add(1)
+	if rl.opts.auditSize <= 0 {
+		return
+	}
+	now := rl.opts.clock.Now()
+
+	var audit *ring[time.Time]
+	s.mu.Lock()
+	if e, ok := s.users[res.Key]; ok {
+		if e.audit == nil {
+			e.audit = newRing[time.Time](rl.opts.auditSize)
+		}
+		audit = e.audit
+	}
+	s.mu.Unlock()
+	if audit != nil {
+		audit.add(now)
+	}
+}
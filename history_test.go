@@ -0,0 +1,98 @@
+package ratata
+
+import (
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRecentDecisionsKeepsLastN(t *testing.T) {
+	rl := NewRatataLimiter(1, time.Hour, WithRecentHistory(3))
+	for i := range 5 {
+		rl.AllowUser("user-" + strconv.Itoa(i))
+	}
+
+	got := rl.RecentDecisions()
+	if len(got) != 3 {
+		t.Fatalf("RecentDecisions returned %d decisions, want 3", len(got))
+	}
+	for i, d := range got {
+		if want := "user-" + strconv.Itoa(i+2); d.Key != want || !d.Allowed {
+			t.Errorf("decision %d = %+v, want an allowed one for %s", i, d, want)
+		}
+	}
+}
+
+func TestRecentDecisionsPartial(t *testing.T) {
+	rl := NewRatataLimiter(1, time.Hour, WithRecentHistory(3))
+	rl.AllowUser("alice")
+	rl.AllowUser("alice")
+
+	got := rl.RecentDecisions()
+	if len(got) != 2 || !got[0].Allowed || got[1].Allowed {
+		t.Errorf("RecentDecisions = %+v, want alice allowed then denied", got)
+	}
+	if NewRatataLimiter(1, time.Hour).RecentDecisions() != nil {
+		t.Error("RecentDecisions without WithRecentHistory isn't nil")
+	}
+}
+
+func TestUserAuditLogKeepsLastN(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(10, time.Hour, WithClock(clock), WithAuditHistory(3))
+
+	var times []time.Time
+	for range 5 {
+		clock.Advance(time.Second)
+		times = append(times, clock.Now())
+		rl.AllowUser("alice")
+	}
+
+	got := rl.UserAuditLog("alice")
+	if len(got) != 3 {
+		t.Fatalf("UserAuditLog returned %d entries, want 3", len(got))
+	}
+	for i, want := range times[2:] {
+		if !got[i].Equal(want) {
+			t.Errorf("entry %d = %v, want %v", i, got[i], want)
+		}
+	}
+}
+
+func TestUserAuditLogDisabled(t *testing.T) {
+	rl := NewRatataLimiter(1, time.Hour)
+	rl.AllowUser("alice")
+	if got := rl.UserAuditLog("alice"); got != nil {
+		t.Errorf("UserAuditLog without WithAuditHistory = %v, want nil", got)
+	}
+	if got := NewRatataLimiter(1, time.Hour, WithAuditHistory(3)).UserAuditLog("bob"); got != nil {
+		t.Errorf("UserAuditLog for an unknown user = %v, want nil", got)
+	}
+}
+
+// TestUserAuditLogConcurrent reads each user's audit log while the user's first
+// allowed action is creating it; run with -race.
+func TestUserAuditLogConcurrent(t *testing.T) {
+	rl := NewRatataLimiter(1000, time.Hour, WithAuditHistory(4))
+
+	var latest atomic.Int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range int64(5000) {
+			latest.Store(i)
+			rl.AllowUser("user-" + strconv.FormatInt(i, 10))
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+			rl.UserAuditLog("user-" + strconv.FormatInt(latest.Load(), 10))
+			runtime.Gosched()
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package ratata
+
+import "time"
+
+// EvictIdle forgets the users whose bucket is full and who have had no admission
+// check for at least idle, and returns how many were evicted; see WithIdleTTL.
+// Users whose limiter, built by a bucket factory, can't report whether it is full
+// are evicted on idleness alone. Buckets are checked without holding the user map
+// locked, and a user accessed in the meantime is kept. Expired auto-block denial
+// counts are dropped as well, including those of users kept in a Store.
+func (rl *RatataLimiter) EvictIdle(idle time.Duration) int {
+	now := rl.opts.clock.Now()
+	cutoff := now.Add(-idle)
+	if rl.opts.autoBlock.threshold > 0 {
+		rl.sweepDenials(now)
+	}
+
+	candidates := make(map[string]*userEntry)
+	rl.rangeUsers(func(id string, e *userEntry) {
+		if e.lastAccess.Before(cutoff) {
+			candidates[id] = e
+		}
+	})
+
+	for id, e := range candidates {
+		if !limiterFull(e.limiter) {
+			delete(candidates, id)
+		}
+	}
+
+	evicted := 0
+	for id, e := range candidates {
+		s := rl.shard(id)
+		s.mu.Lock()
+		if s.users[id] == e && e.lastAccess.Before(cutoff) {
+			rl.deleteLocked(s, id, e)
+			evicted++
+		}
+		s.mu.Unlock()
+	}
+	if evicted > 0 {
+		rl.opts.logger.Debugf("ratata: evicted %d idle users", evicted)
+	}
+	return evicted
+}
+
+// limiterFull reports whether l is full, or true if it can't tell.
+func limiterFull(l Limiter) bool {
+	switch c := l.(type) {
+	case *RatataBucket:
+		return c.IsFull()
+	case interface{ Capacity() int }:
+		return l.Tokens() >= c.Capacity()
+	default:
+		return true
+	}
+}
+
+// startJanitor starts the goroutine that evicts idle users every ttl.
+func (rl *RatataLimiter) startJanitor(ttl time.Duration) {
+	stop := make(chan struct{})
+	rl.stopJanitor = stop
+
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-rl.opts.clock.After(ttl):
+				rl.EvictIdle(ttl)
+			}
+		}
+	}()
+}
+
+// Close stops the background janitor started by WithIdleTTL. It is safe to call
+// more than once, always returns nil and does nothing for a limiter without a
+// janitor. The limiter keeps working after Close; idle users are then only evicted
+// by EvictIdle.
+func (rl *RatataLimiter) Close() error {
+	rl.closeOnce.Do(func() {
+		if rl.stopJanitor != nil {
+			close(rl.stopJanitor)
+		}
+	})
+	return nil
+}
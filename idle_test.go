@@ -0,0 +1,55 @@
+package ratata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIdleTTLJanitor(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(2, time.Hour, WithClock(clock), WithIdleTTL(time.Minute))
+	defer rl.Close()
+	rl.SetUserMeta("idle", "full") // Tracked with a full bucket.
+	rl.AllowUserResultN("busy", 2)
+	rl.AllowUser("recent")
+
+	// The first sweep, after one TTL, finds no user idle for longer than that.
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	clock.BlockUntil(1)
+	clock.Advance(30 * time.Second)
+	rl.AllowUser("recent")
+	clock.Advance(30 * time.Second)
+	clock.BlockUntil(1) // The janitor has swept again and waits for the next round.
+
+	// Only the full bucket untouched for the whole TTL goes; a drained bucket would
+	// still deny, so it is kept however long it is idle.
+	if got := rl.Len(); got != 2 {
+		t.Errorf("Len after a sweep = %d, want 2", got)
+	}
+	if got := rl.Stats().Users; got != 2 {
+		t.Errorf("Stats.Users = %d, want 2", got)
+	}
+	for _, id := range []string{"busy", "recent"} {
+		if _, ok := rl.UserMeta(id); !ok {
+			t.Errorf("%s was evicted", id)
+		}
+	}
+
+	rl.Close()
+	rl.Close() // Closing twice is harmless.
+}
+
+func TestEvictIdle(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(1, time.Hour, WithClock(clock))
+	rl.AllowUser("alice")
+	rl.SetUserMeta("bob", 1)
+	clock.Advance(time.Hour)
+	if got := rl.EvictIdle(time.Minute); got != 2 {
+		t.Errorf("EvictIdle = %d, want both refilled users", got)
+	}
+	if got := rl.Len(); got != 0 {
+		t.Errorf("Len = %d, want 0", got)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build ratata_invariants
+
+package ratata
+
+import "fmt"
+
+// checkRatata panics if the bucket's state is inconsistent: tokens must stay within
+// [0, capacity] plus any welcome burst, with no lower bound in overdraft mode or once
+// Reserve has booked tokens ahead. It is compiled in with the ratata_invariants
+// build tag, e.g. go test -tags ratata_invariants, and is a no-op otherwise. The
+// caller must hold rb.mu.
+func (rb *RatataBucket) checkRatata() {
+	if rb.tokens > rb.capacity+rb.welcome || (rb.tokens < 0 && !rb.opts.overdraft && !rb.booked) {
+		panic(fmt.Sprintf("ratata: invariant violated: %d tokens in a bucket of capacity %d", rb.tokens, rb.capacity))
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !ratata_invariants
+
+package ratata
+
+// checkRatata is a no-op without the ratata_invariants build tag; see invariants.go.
+func (rb *RatataBucket) checkRatata() {}
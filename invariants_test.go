@@ -0,0 +1,102 @@
+package ratata
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// FuzzRefill drives a bucket with a random configuration through a random sequence
+// of clock advances and operations, and checks that its balance stays within
+// [0, capacity] and that it never admits more than its capacity plus what refill
+// earned over the elapsed time. Run it with -tags ratata_invariants to also check the
+// bucket's state after every operation inside the package.
+//
+// Each three bytes of ops are one step: an operation, a clock advance in 32nds of the
+// refill rate, and a token count.
+func FuzzRefill(f *testing.F) {
+	f.Add(uint16(10), uint32(time.Millisecond), uint8(0), uint8(1), []byte{0, 32, 1, 0, 0, 5, 1, 16, 0})
+	f.Add(uint16(1), uint32(1), uint8(2), uint8(1), []byte{0, 255, 1, 0, 1, 1, 2, 31, 0, 3, 0, 1})
+	f.Add(uint16(50), uint32(time.Second), uint8(1), uint8(3), []byte{0, 16, 50, 0, 17, 49, 3, 1, 2, 2, 200, 0})
+	f.Add(uint16(0), uint32(time.Microsecond), uint8(0), uint8(1), []byte{0, 255, 1, 1, 255, 0})
+
+	f.Fuzz(func(t *testing.T, capacity uint16, rate uint32, mode, perInterval uint8, ops []byte) {
+		refillRate := time.Duration(max(rate, 1))
+		perTick := int(perInterval%4) + 1
+		clock := newFakeClock()
+		b := NewRatataBucket(int(capacity), refillRate, WithClock(clock),
+			WithRoundingMode(RoundingMode(mode%3)), WithTokensPerInterval(perTick))
+
+		start := clock.Now()
+		admitted := 0
+		for i := 0; i+2 < len(ops) && i < 3000; i += 3 {
+			clock.Advance(time.Duration(ops[i+1]) * refillRate / 32)
+			n := int(ops[i+2])
+			switch ops[i] % 4 {
+			case 0:
+				if b.AllowN(n) {
+					admitted += n
+				}
+			case 1:
+				if b.Allow() {
+					admitted++
+				}
+			case 2:
+				b.Tokens()
+			case 3:
+				if b.AllowN(n) {
+					b.refund(n) // Refund clamps to the capacity; see the balance check.
+				}
+			}
+			if got := b.Balance(); got < 0 || got > int(capacity) {
+				t.Fatalf("step %d: balance %d outside [0, %d]", i/3, got, capacity)
+			}
+		}
+
+		elapsed := clock.Now().Sub(start)
+		if limit := int(capacity) + perTick*(int(elapsed/refillRate)+1); admitted > limit {
+			t.Fatalf("admitted %d tokens over %v, want at most %d", admitted, elapsed, limit)
+		}
+
+		// However it was used, a bucket left alone long enough is full again.
+		clock.Advance(time.Duration((int(capacity)+perTick-1)/perTick) * refillRate)
+		if got := b.Balance(); got != int(capacity) {
+			t.Fatalf("balance %d after a full refill interval, want %d", got, capacity)
+		}
+	})
+}
+
+func TestRefillHugeGap(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(math.MaxInt32, 1, WithClock(clock), WithRoundingMode(RoundCeil))
+	b.AllowN(5)
+	b.lastRefill = time.Time{} // Centuries of refill at one token a nanosecond.
+	if got := b.Balance(); got != math.MaxInt32 {
+		t.Errorf("Balance after a huge gap = %d, want %d", got, math.MaxInt32)
+	}
+}
+
+func TestRefillFullBucketDoesNotBankTime(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(2, time.Second, WithClock(clock))
+	clock.Advance(900 * time.Millisecond) // Time spent full earns nothing.
+	b.Allow()
+	clock.Advance(200 * time.Millisecond)
+	if got := b.Tokens(); got != 1 {
+		t.Errorf("Tokens = %d, want 1 with the next token due 800ms later", got)
+	}
+}
+
+func TestRefillRoundCeilAtCapacity(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(1, time.Second, WithClock(clock), WithRoundingMode(RoundCeil))
+	b.Allow()
+	clock.Advance(500 * time.Millisecond) // Rounded up to a token that fills the bucket.
+	if !b.Allow() {
+		t.Fatal("denied the token rounded up after half an interval")
+	}
+	clock.Advance(500 * time.Millisecond)
+	if b.Allow() {
+		t.Error("admitted a third token within one second: the rounded-up token was credited twice")
+	}
+}
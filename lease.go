@@ -0,0 +1,180 @@
+package ratata
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// LeaseStore is a Store that cuts round trips to a shared backend Store by taking
+// tokens from it in batches, or leases, and serving takes from the lease locally
+// until it runs out. Every token served was first taken from the backend, so the
+// instances sharing a backend never admit more than its limit in total; the cost is
+// precision, as tokens leased by one instance sit unused while another is denied.
+// Smaller batches and shorter lease times trade throughput back for precision.
+//
+// When the backend can't supply a whole batch, a take asks it for the tokens it
+// reports as remaining, provided they cover the take. A lease expires once it has
+// been held for as long as its time to live, and its unused tokens are then given
+// back if the backend is a Refunder, or dropped otherwise. Close gives back all
+// unused tokens on shutdown.
+type LeaseStore struct {
+	backend Store         // Store the tokens are leased from.
+	batch   int           // Number of tokens to lease at once.
+	ttl     time.Duration // How long a lease may be held.
+
+	leases map[string]*lease // Current leases by key.
+	mu     sync.Mutex        // Mutex to protect leases.
+}
+
+// lease is the batch of tokens a LeaseStore holds for one key.
+type lease struct {
+	tokens  int         // Unused leased tokens.
+	expires time.Time   // Time the lease must be given back.
+	last    TakeRequest // Latest take for the key, used to give tokens back.
+	dead    bool        // Whether the lease was removed from the store.
+	mu      sync.Mutex  // Mutex to protect the lease; held during backend calls.
+}
+
+var _ Store = (*LeaseStore)(nil)
+
+// NewLeaseStore returns a LeaseStore that leases batch tokens at a time from backend
+// and holds each lease for at most ttl. A batch below 1 leases one token at a time.
+func NewLeaseStore(backend Store, batch int, ttl time.Duration) *LeaseStore {
+	return &LeaseStore{
+		backend: backend,
+		batch:   max(batch, 1),
+		ttl:     ttl,
+		leases:  make(map[string]*lease),
+	}
+}
+
+// Take serves req from the key's lease, leasing more tokens from the backend first
+// if the lease has too few. Other keys are served while the backend is called.
+func (ls *LeaseStore) Take(ctx context.Context, req TakeRequest) (TakeResult, error) {
+	for {
+		l := ls.lease(req.Key)
+		l.mu.Lock()
+		if l.dead {
+			l.mu.Unlock()
+			continue // Removed while we waited for it; start over with a fresh lease.
+		}
+		res, err := ls.takeLocked(ctx, req, l)
+		if l.tokens == 0 {
+			ls.removeLocked(req.Key, l)
+		}
+		l.mu.Unlock()
+		return res, err
+	}
+}
+
+// takeLocked serves req from l. The caller must hold l.mu.
+func (ls *LeaseStore) takeLocked(ctx context.Context, req TakeRequest, l *lease) (TakeResult, error) {
+	l.last = req
+	if l.tokens > 0 && !req.Now.Before(l.expires) {
+		ls.giveBackLocked(ctx, l) // Errors only lose unused tokens, so don't fail the take.
+	}
+
+	if missing := req.N - l.tokens; missing > 0 {
+		got, res, err := ls.leaseMore(ctx, req, missing)
+		if err != nil {
+			return TakeResult{}, err
+		}
+		if got == 0 {
+			res.Remaining += l.tokens
+			return res, nil
+		}
+		if l.tokens == 0 {
+			l.expires = req.Now.Add(ls.ttl)
+		}
+		l.tokens += got
+	}
+
+	l.tokens -= max(req.N, 0)
+	return TakeResult{Allowed: true, Remaining: l.tokens}, nil
+}
+
+// leaseMore takes a batch of tokens from the backend, or whatever it has left if it
+// can't supply a batch but has at least the missing tokens, and returns how many it
+// got along with the backend's result.
+func (ls *LeaseStore) leaseMore(ctx context.Context, req TakeRequest, missing int) (int, TakeResult, error) {
+	want := max(ls.batch, missing)
+	for {
+		breq := req
+		breq.N = want
+		res, err := ls.backend.Take(ctx, breq)
+		if err != nil || res.Allowed {
+			return want, res, err
+		}
+		if want == missing || res.Remaining < missing {
+			return 0, res, nil // Asking again can't succeed.
+		}
+		want = max(min(res.Remaining, want-1), missing)
+	}
+}
+
+// giveBackLocked returns l's unused tokens to the backend if it is a Refunder, and
+// drops them otherwise. The caller must hold l.mu.
+func (ls *LeaseStore) giveBackLocked(ctx context.Context, l *lease) error {
+	n := l.tokens
+	l.tokens = 0
+	r, ok := ls.backend.(Refunder)
+	if !ok || n == 0 {
+		return nil
+	}
+	req := l.last
+	req.N = n
+	return r.Refund(ctx, req)
+}
+
+// lease returns the lease for key, creating an empty one if it doesn't exist.
+func (ls *LeaseStore) lease(key string) *lease {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	l, ok := ls.leases[key]
+	if !ok {
+		l = &lease{}
+		ls.leases[key] = l
+	}
+	return l
+}
+
+// removeLocked removes l, the lease of key, from the store. The caller must hold l.mu.
+func (ls *LeaseStore) removeLocked(key string, l *lease) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	l.dead = true
+	if ls.leases[key] == l {
+		delete(ls.leases, key)
+	}
+}
+
+// Close gives every unused leased token back to the backend if it is a Refunder,
+// for a clean shutdown, and returns the errors the backend reported. Each key's
+// tokens are given back as of its latest take. The store remains usable.
+func (ls *LeaseStore) Close(ctx context.Context) error {
+	ls.mu.Lock()
+	keys := make([]string, 0, len(ls.leases))
+	leases := make([]*lease, 0, len(ls.leases))
+	for key, l := range ls.leases {
+		keys = append(keys, key)
+		leases = append(leases, l)
+	}
+	ls.mu.Unlock()
+
+	var errs []error
+	for i, l := range leases {
+		l.mu.Lock()
+		if !l.dead {
+			if err := ls.giveBackLocked(ctx, l); err != nil {
+				errs = append(errs, err)
+			}
+			ls.removeLocked(keys[i], l)
+		}
+		l.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
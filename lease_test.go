@@ -0,0 +1,89 @@
+package ratata
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// countingStore is a MemoryStore that counts its takes.
+type countingStore struct {
+	*MemoryStore
+	takes int
+}
+
+func (s *countingStore) Take(ctx context.Context, req TakeRequest) (TakeResult, error) {
+	s.takes++
+	return s.MemoryStore.Take(ctx, req)
+}
+
+// tokens returns the tokens left in the backend's bucket for key as of its last refill.
+func (s *countingStore) tokens(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buckets[key].tokens
+}
+
+func TestLeaseStoreStaysWithinGlobalLimit(t *testing.T) {
+	clock := newFakeClock()
+	backend := &countingStore{MemoryStore: NewMemoryStore()}
+	var stores []*LeaseStore
+	var instances []*RatataLimiter
+	for range 3 {
+		ls := NewLeaseStore(backend, 10, time.Second)
+		stores = append(stores, ls)
+		instances = append(instances, NewRatataLimiter(100, 100*time.Millisecond, WithClock(clock), WithStore(ls)))
+	}
+
+	admitted := 0
+	for range 100 {
+		clock.Advance(10 * time.Millisecond)
+		for _, rl := range instances {
+			for range 5 {
+				if rl.AllowUser("alice") {
+					admitted++
+				}
+			}
+		}
+	}
+	// Over one second the shared bucket holds 100 tokens and earns 10 more.
+	if admitted < 100 || admitted > 110 {
+		t.Errorf("instances admitted %d actions in total, want 100 to 110", admitted)
+	}
+
+	for _, ls := range stores {
+		if err := ls.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(ls.leases) != 0 {
+			t.Errorf("Close left %d leases", len(ls.leases))
+		}
+	}
+}
+
+func TestLeaseStoreRefreshesExpiredLease(t *testing.T) {
+	clock := newFakeClock()
+	backend := &countingStore{MemoryStore: NewMemoryStore()}
+	ls := NewLeaseStore(backend, 10, time.Second)
+	rl := NewRatataLimiter(100, time.Hour, WithClock(clock), WithStore(ls))
+
+	rl.AllowUser("alice")
+	rl.AllowUser("alice")
+	if got := backend.tokens("alice"); got != 90 || backend.takes != 1 {
+		t.Fatalf("backend has %d tokens after %d takes, want one lease of 10", got, backend.takes)
+	}
+
+	// The expired lease gives back its 8 unused tokens before a new one is taken.
+	clock.Advance(2 * time.Second)
+	rl.AllowUser("alice")
+	if got := backend.tokens("alice"); got != 88 {
+		t.Errorf("backend has %d tokens after the lease expired, want 88", got)
+	}
+
+	if err := ls.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := backend.tokens("alice"); got != 97 {
+		t.Errorf("backend has %d tokens after Close, want 97 with the 3 used", got)
+	}
+}
@@ -0,0 +1,242 @@
+package ratata
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RatataLimiter keeps an independent token bucket for every user. Each user's
+// bucket is created on first use with the limiter's capacity and refill rate, and
+// the limiter tracks aggregate counters across all of them. Users are kept in a map
+// split into shards by a hash of the user ID, each with its own mutex, so calls for
+// different users rarely contend on a lock.
+//
+// A RatataLimiter must not be copied after first use; use a *RatataLimiter.
+type RatataLimiter struct {
+	_ noCopy
+
+	capacity   int                   // Capacity of each user's bucket.
+	refillRate time.Duration         // Refill rate of each user's bucket.
+	opts       options               // Optional settings, copied into each user's bucket.
+	shards     [shardCount]userShard // State kept for each user, sharded by user ID.
+	userCount  atomic.Int64          // Number of users in all shards.
+	memory     atomic.Int64          // Estimated bytes of all users.
+	evictMu    sync.Mutex            // Serializes evictions; see enforceLimits.
+	allowed    atomic.Uint64         // Number of allowed actions across all users.
+	denied     atomic.Uint64         // Number of denied actions across all users.
+	rejected   atomic.Uint64         // Number of tokens requested by denied actions.
+	started    time.Time             // Time the limiter was created.
+	history    *ring[Decision]       // Recent decisions, if WithRecentHistory is set.
+	global     *atomicBucket         // Bucket shared by all callers of Allow.
+	degraded   atomic.Uint64         // Bits of the SetDegraded factor.
+	hasMeta    atomic.Bool           // Whether SetUserMeta has ever been called.
+
+	globalLimit *globalLimit // Limit shared by all users on top of their own, if set.
+	stopOnce    sync.Once    // Makes Stop flush stats only once.
+
+	stopJanitor chan struct{} // Closed to stop the idle-user janitor; nil if there is none.
+	closeOnce   sync.Once     // Makes Close stop the janitor only once.
+
+	blocked    map[string]time.Time     // Blocked users and when their block ends.
+	denials    map[string]*denialWindow // Recent denials per user, for auto-blocking.
+	blockCount atomic.Int64             // Number of entries in blocked.
+	blockMu    sync.Mutex               // Mutex to protect blocked and denials.
+
+	limits   map[string]TierConfig // Limits set with SetUserLimit, kept across evictions.
+	limitsMu sync.Mutex            // Mutex to protect limits.
+}
+
+// userEntry is the state a RatataLimiter keeps for one user.
+type userEntry struct {
+	limiter    Limiter          // The user's rate limiter, usually a token bucket.
+	lastAccess time.Time        // Time of the user's last admission check.
+	meta       any              // Metadata attached with SetUserMeta.
+	audit      *ring[time.Time] // Times of recent allowed actions, if WithAuditHistory is set.
+	bytes      int              // Estimated size of the user's state; see EstimateUserBytes.
+	reset      userReset        // Scheduled reset to a full bucket, if any.
+}
+
+// NewRatataLimiter creates a limiter whose users each get a bucket with the given
+// capacity and refill rate. Options apply to every user's bucket.
+func NewRatataLimiter(capacity int, refillRate time.Duration, opts ...Option) *RatataLimiter {
+	o := newOptions(opts)
+	rl := &RatataLimiter{
+		capacity:   capacity,
+		refillRate: refillRate,
+		opts:       o,
+		started:    o.clock.Now(),
+	}
+	rl.global = newAtomicBucket(capacity, rl.tokenRate(), o.clock.Now())
+	rl.degraded.Store(math.Float64bits(1))
+	if g := o.globalLimit; g.capacity > 0 && g.refillRate > 0 {
+		rl.globalLimit = newGlobalLimit(g.capacity, g.refillRate, o)
+	}
+	if o.historySize > 0 {
+		rl.history = newRing[Decision](o.historySize)
+	}
+	if o.idleTTL > 0 {
+		rl.startJanitor(o.idleTTL)
+	}
+	return rl
+}
+
+// AllowUser checks if an action is allowed for userID, creating the user's bucket
+// if it doesn't exist, and records the decision in the limiter's counters. Once the
+// user's bucket exists, AllowUser does not allocate.
+func (rl *RatataLimiter) AllowUser(userID string) bool {
+	return rl.AllowUserResult(userID).Allowed
+}
+
+// AllowUserUnless behaves like AllowUser unless exempt is set, in which case it
+// allows the action right away, for one-off exemptions such as an internal retry
+// carrying an exemption token. An exempt call doesn't consume tokens, create the
+// user, or count as a decision in the limiter's stats.
+func (rl *RatataLimiter) AllowUserUnless(userID string, exempt bool) bool {
+	if exempt {
+		return true
+	}
+	return rl.AllowUser(userID)
+}
+
+// key returns the normalized form of userID; see WithKeyNormalizer.
+func (rl *RatataLimiter) key(userID string) string {
+	if rl.opts.normalize == nil {
+		return userID
+	}
+	return rl.opts.normalize(userID)
+}
+
+// tokenRate returns the time to earn one token in each user's bucket, rounded to the
+// nanosecond; see WithTokensPerInterval.
+func (rl *RatataLimiter) tokenRate() time.Duration {
+	return max(rl.refillRate/rl.opts.perInterval, 1)
+}
+
+// userLimiter returns the limiter for userID, creating it under a single acquisition
+// of its shard's mutex so concurrent first calls for the same user share one
+// limiter. Looking a user up counts as an access for eviction purposes.
+func (rl *RatataLimiter) userLimiter(userID string) Limiter {
+	s := rl.shard(userID)
+	s.mu.Lock()
+	now := rl.opts.clock.Now()
+	e, ok := s.users[userID]
+	if !ok {
+		e = rl.addUserLocked(s, userID, rl.newUserLimiter(userID), now)
+	}
+	e.lastAccess = now
+	l, reset := e.limiter, e.reset.dueLocked(now)
+	s.mu.Unlock()
+
+	if !ok {
+		rl.enforceLimits(userID)
+	}
+	if reset {
+		resetLimiter(l)
+	}
+	return l
+}
+
+// Remove stops tracking userID and reports whether it was tracked, dropping its
+// bucket along with any metadata, audit history and scheduled reset; if seen again,
+// the user starts over with a full bucket. A block on the user is kept; see Unblock.
+func (rl *RatataLimiter) Remove(userID string) bool {
+	userID = rl.key(userID)
+	s := rl.shard(userID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.users[userID]
+	if ok {
+		rl.deleteLocked(s, userID, e)
+	}
+	return ok
+}
+
+// newUserLimiter builds the limiter for a new user, using the limit set with
+// SetUserLimit if there is one, then the configured factory, then the user's tier,
+// and otherwise a bucket with the limiter's own settings.
+func (rl *RatataLimiter) newUserLimiter(userID string) Limiter {
+	if limit, ok := rl.userLimit(userID); ok {
+		return rl.buildUserLimiter(userID, limit.Capacity, limit.RefillRate)
+	}
+	if rl.opts.factory != nil {
+		if l := rl.opts.factory(userID); l != nil {
+			return l
+		}
+	}
+	if rl.opts.tiers != nil {
+		if tier, ok := rl.userTier(userID); ok {
+			return rl.buildUserLimiter(userID, tier.Capacity, tier.RefillRate)
+		}
+	}
+	return rl.buildUserLimiter(userID, rl.capacity, rl.refillRate)
+}
+
+// buildUserLimiter creates a limiter with the given settings for userID, of the
+// configured algorithm.
+func (rl *RatataLimiter) buildUserLimiter(userID string, capacity int, refillRate time.Duration) Limiter {
+	if rl.opts.algorithm != TokenBucket {
+		return newAlgorithmLimiter(capacity, refillRate, rl.opts)
+	}
+	return rl.newUserBucket(userID, capacity, refillRate)
+}
+
+// newUserBucket creates a full bucket for userID, wired to the limiter's per-user hooks.
+func (rl *RatataLimiter) newUserBucket(userID string, capacity int, refillRate time.Duration) *RatataBucket {
+	b := newBucket(capacity, refillRate, rl.opts)
+	if onRecover := rl.opts.onRecover; onRecover != nil {
+		b.onRecover = func() { onRecover(userID) }
+	}
+	return b
+}
+
+// record adds a decision for n tokens to the aggregate counters and the recent
+// history, and passes it to the decision hook, stamped with the current time if it
+// has none.
+func (rl *RatataLimiter) record(res Result, n int) {
+	if res.Time.IsZero() {
+		res.Time = rl.opts.clock.Now()
+	}
+	if res.Allowed {
+		rl.allowed.Add(1)
+	} else {
+		rl.denied.Add(1)
+		saturatingAdd(&rl.rejected, uint64(max(n, 0)))
+	}
+	if rl.history != nil {
+		rl.history.add(Decision{Time: res.Time, Key: res.Key, Allowed: res.Allowed})
+	}
+	if rl.opts.onDecision != nil {
+		rl.opts.onDecision(res)
+	}
+}
+
+// saturatingAdd adds delta to c, stopping at the maximum value instead of wrapping.
+func saturatingAdd(c *atomic.Uint64, delta uint64) {
+	for {
+		old := c.Load()
+		next := old + delta
+		if next < old {
+			next = math.MaxUint64
+		}
+		if c.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+// SetUserRateMultiplier speeds up or slows down refill for a single user, making the
+// effective refill rate the user's base rate divided by factor. A factor above 1
+// refills faster, below 1 slower, and 1 restores the normal rate. Accrued time is
+// preserved when the multiplier changes. Non-positive factors are ignored, as are
+// users whose limiter, built by a bucket factory, has no rate multiplier.
+func (rl *RatataLimiter) SetUserRateMultiplier(userID string, factor float64) {
+	userID = rl.key(userID)
+	if m, ok := rl.userLimiter(userID).(interface{ SetRateMultiplier(float64) }); ok {
+		m.SetRateMultiplier(factor)
+		rl.opts.logger.Infof("ratata: set rate multiplier of user %q to %g", userID, factor)
+	}
+}
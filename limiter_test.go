@@ -0,0 +1,184 @@
+package ratata
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetUserRateMultiplier(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(1, time.Second, WithClock(clock))
+	rl.AllowUser("normal")
+	rl.AllowUser("double")
+	rl.SetUserRateMultiplier("double", 2)
+
+	admitted := map[string]int{}
+	for range 100 {
+		clock.Advance(100 * time.Millisecond)
+		for _, id := range []string{"normal", "double"} {
+			if rl.AllowUser(id) {
+				admitted[id]++
+			}
+		}
+	}
+	if got := admitted["normal"]; got < 9 || got > 11 {
+		t.Errorf("normal user admitted %d times in 10s at 1/s, want about 10", got)
+	}
+	if got := admitted["double"]; got < 19 || got > 21 {
+		t.Errorf("double user admitted %d times in 10s at 2/s, want about 20", got)
+	}
+}
+
+func TestBucketFactory(t *testing.T) {
+	rl := NewRatataLimiter(1, time.Hour, WithBucketFactory(func(userID string) Limiter {
+		if userID == "pro" {
+			return NewRatataBucket(3, time.Hour)
+		}
+		return nil // Everyone else gets the limiter's own configuration.
+	}))
+
+	admitted := map[string]int{}
+	for range 5 {
+		for _, id := range []string{"pro", "free"} {
+			if rl.AllowUser(id) {
+				admitted[id]++
+			}
+		}
+	}
+	if admitted["pro"] != 3 || admitted["free"] != 1 {
+		t.Errorf("admitted %v, want pro 3 and free 1", admitted)
+	}
+}
+
+func TestMaxUsersEvictsDeterministically(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(1, time.Hour, WithClock(clock), WithMaxUsers(3))
+	rl.AllowUser("carol")
+	rl.AllowUser("bob")
+	clock.Advance(time.Second)
+	rl.AllowUser("alice")
+
+	// carol and bob tie on last access; the smaller ID goes first.
+	for _, step := range []struct{ add, evicted string }{{"dave", "bob"}, {"erin", "carol"}} {
+		rl.AllowUser(step.add)
+		if rl.lookupUser(step.evicted) != nil {
+			t.Errorf("adding %s didn't evict %s", step.add, step.evicted)
+		}
+		if got := rl.Len(); got != 3 {
+			t.Errorf("adding %s left %d users, want 3", step.add, got)
+		}
+	}
+	if rl.lookupUser("alice") == nil {
+		t.Error("alice, the most recently seen of the first three users, was evicted")
+	}
+}
+
+func TestOnRecoverFiresOnce(t *testing.T) {
+	clock := newFakeClock()
+	var recovered []string
+	rl := NewRatataLimiter(2, time.Second, WithClock(clock),
+		WithOnRecover(func(userID string) { recovered = append(recovered, userID) }))
+
+	rl.AllowUser("alice")
+	rl.AllowUser("alice")
+	rl.AllowUser("alice") // Denied: the bucket is empty.
+	clock.Advance(2 * time.Second)
+	if len(recovered) != 0 {
+		t.Fatalf("OnRecover fired before the bucket was accessed again: %v", recovered)
+	}
+
+	rl.AllowUser("alice")
+	rl.AllowUser("alice")
+	if len(recovered) != 1 || recovered[0] != "alice" {
+		t.Errorf("OnRecover calls = %v, want [alice] exactly once", recovered)
+	}
+}
+
+func TestKeyNormalizer(t *testing.T) {
+	rl := NewRatataLimiter(2, time.Hour, WithKeyNormalizer(strings.ToLower))
+
+	rl.AllowUser("User@Example.com")
+	res := rl.AllowUserResult("user@example.com")
+	if !res.Allowed || res.Remaining != 0 || res.Key != "user@example.com" {
+		t.Errorf("second action = %v, %d left, key %q; want the same bucket's last token", res.Allowed, res.Remaining, res.Key)
+	}
+	if got := rl.CountTotalUsers(); got != 1 {
+		t.Errorf("got %d users, want 1", got)
+	}
+	if rl.PeekUser("USER@example.com") {
+		t.Error("PeekUser sees a different bucket")
+	}
+
+	rl.Block("Mallory", time.Hour)
+	if !rl.IsBlocked("mallory") {
+		t.Error("Block didn't normalize the key")
+	}
+	if _, err := rl.TransferTokens("Alice", "alice", 1); err != ErrSameUser {
+		t.Errorf("TransferTokens between forms of one key = %v, want %v", err, ErrSameUser)
+	}
+}
+
+func TestAllowUserUnless(t *testing.T) {
+	rl := NewRatataLimiter(2, time.Hour)
+	for range 5 {
+		if !rl.AllowUserUnless("alice", true) {
+			t.Fatal("an exempt call was denied")
+		}
+	}
+	if got := rl.CountTotalUsers(); got != 0 {
+		t.Errorf("exempt calls created %d users, want none", got)
+	}
+
+	if !rl.AllowUserUnless("alice", false) {
+		t.Fatal("the first non-exempt call was denied")
+	}
+	rl.AllowUserUnless("alice", true)
+	if got := rl.userLimiter("alice").Tokens(); got != 1 {
+		t.Errorf("alice has %d tokens, want 1 with only the non-exempt call charged", got)
+	}
+	rl.AllowUserUnless("alice", false)
+	if rl.AllowUserUnless("alice", false) {
+		t.Error("a non-exempt call to a drained user was allowed")
+	}
+}
+
+func TestLimitersAreIndependent(t *testing.T) {
+	small := NewRatataLimiter(1, time.Hour)
+	large := NewRatataLimiter(3, time.Hour)
+	for i, want := range []bool{true, false, false} {
+		if got := small.AllowUser("alice"); got != want {
+			t.Errorf("small limiter action %d = %v, want %v", i, got, want)
+		}
+	}
+	for i := range 3 {
+		if !large.AllowUser("alice") {
+			t.Errorf("large limiter action %d was denied by the small limiter's state", i)
+		}
+	}
+}
+
+func TestLimiterRemove(t *testing.T) {
+	rl := NewRatataLimiter(1, time.Hour, WithMaxMemoryBytes(1<<20))
+	rl.AllowUser("alice")
+	if got := rl.Len(); got != 1 {
+		t.Fatalf("Len = %d, want 1", got)
+	}
+	if !rl.Remove("alice") || rl.Remove("alice") {
+		t.Error("Remove should report true only for a tracked user")
+	}
+	if rl.Len() != 0 || rl.MemoryBytes() != 0 {
+		t.Errorf("got %d users in %d bytes after Remove, want none", rl.Len(), rl.MemoryBytes())
+	}
+	if !rl.AllowUser("alice") {
+		t.Error("a removed user didn't start again with a full bucket")
+	}
+}
+
+func TestDeprecatedAllowUser(t *testing.T) {
+	b := NewRatataBucket(1, time.Hour)
+	const id = "TestDeprecatedAllowUser" // The default limiter is shared by the package.
+	if !b.AllowUser(id) || b.AllowUser(id) {
+		t.Error("the deprecated AllowUser didn't admit exactly one action")
+	}
+}
@@ -0,0 +1,238 @@
+package ratata
+
+import (
+	"errors"
+	"time"
+)
+
+// validateConfig checks that capacity and refillRate describe a usable bucket. A
+// capacity of zero is valid and makes a bucket that denies everything.
+func validateConfig(capacity int, refillRate time.Duration) error {
+	if capacity < 0 {
+		return ErrInvalidCapacity
+	}
+	if refillRate <= 0 {
+		return ErrInvalidRefillRate
+	}
+	return nil
+}
+
+// SetCapacity changes the maximum number of tokens the bucket can hold. Tokens
+// earned so far are credited first; if the bucket holds more than the new capacity
+// the excess is dropped, and a larger capacity is filled by refill, not granted
+// outright. A capacity of zero makes the bucket deny everything from then on, and
+// negative capacities are ignored.
+func (rb *RatataBucket) SetCapacity(capacity int) {
+	rb.SetLimit(capacity, 0)
+}
+
+// SetLimit changes the bucket's capacity and refill rate together, under a single
+// lock, so no caller sees one without the other. Tokens are clamped as described for
+// SetCapacity and accrued time is preserved as described for SetRefillRate. A zero
+// refillRate keeps the current rate. It returns an error, and changes nothing, if
+// the configuration is invalid.
+func (rb *RatataBucket) SetLimit(capacity int, refillRate time.Duration) error {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if refillRate == 0 {
+		refillRate = rb.baseRate
+	}
+	if err := validateConfig(capacity, refillRate); err != nil {
+		return err
+	}
+	rb.setLimitRatata(rb.opts.clock.Now(), capacity, refillRate)
+	return nil
+}
+
+// Config is the complete configuration of a RatataBucket, for changing several
+// settings at once with Reconfigure.
+type Config struct {
+	Capacity       int           // Maximum number of tokens the bucket can hold.
+	RefillRate     time.Duration // Duration to add one token, before the rate multiplier.
+	RateMultiplier float64       // Factor by which refill is sped up; zero means 1.
+	Reserve        int           // Tokens only AllowPriority may consume; see WithReserve.
+}
+
+// Config returns the bucket's current configuration, as a copy that later changes to
+// the bucket don't affect.
+func (rb *RatataBucket) Config() Config {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	return Config{
+		Capacity:       rb.capacity,
+		RefillRate:     rb.baseRate,
+		RateMultiplier: rb.multiplier,
+		Reserve:        rb.opts.reserve,
+	}
+}
+
+// Equal reports whether c and other configure a bucket the same way, for detecting
+// drift between a running bucket's Config and the desired one. A RateMultiplier of
+// zero equals one of 1, as Reconfigure treats it, and a negative Reserve equals zero.
+func (c Config) Equal(other Config) bool {
+	return c.normalized() == other.normalized()
+}
+
+// normalized returns c with its defaults spelled out, as Reconfigure applies them.
+func (c Config) normalized() Config {
+	if c.RateMultiplier == 0 {
+		c.RateMultiplier = 1
+	}
+	c.Reserve = max(c.Reserve, 0)
+	return c
+}
+
+// Reconfigure replaces the bucket's whole configuration under a single lock, so
+// concurrent callers see either the old configuration or the new one, never a mix
+// of the two as applying SetCapacity, SetRefillRate and SetRateMultiplier one at a
+// time could expose. Tokens earned under the old configuration are credited first,
+// then clamped to the new capacity as described for SetCapacity, and accrued time is
+// preserved as described for SetRefillRate. It returns an error, and changes
+// nothing, if the configuration is invalid.
+func (rb *RatataBucket) Reconfigure(cfg Config) error {
+	if err := validateConfig(cfg.Capacity, cfg.RefillRate); err != nil {
+		return err
+	}
+	if cfg.RateMultiplier < 0 {
+		return ErrInvalidRateMultiplier
+	}
+	if cfg.RateMultiplier == 0 {
+		cfg.RateMultiplier = 1
+	}
+
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.baseRate = cfg.RefillRate
+	rb.multiplier = cfg.RateMultiplier
+	rb.setRateRatata(rb.opts.clock.Now(), rb.scaledRate())
+	rb.capacity = cfg.Capacity
+	rb.tokens = min(rb.tokens, cfg.Capacity)
+	rb.welcome = 0
+	rb.opts.reserve = max(cfg.Reserve, 0)
+	return nil
+}
+
+// setLimitRatata applies a validated capacity and base refill rate as of now.
+// The caller must hold rb.mu.
+func (rb *RatataBucket) setLimitRatata(now time.Time, capacity int, refillRate time.Duration) {
+	if capacity == rb.capacity && refillRate == rb.baseRate {
+		return // Nothing changes; keep the common re-apply path cheap.
+	}
+
+	rb.baseRate = refillRate
+	rb.setRateRatata(now, rb.scaledRate())
+	rb.capacity = capacity
+	rb.tokens = min(rb.tokens, capacity) // Drop tokens that no longer fit.
+	rb.welcome = 0
+}
+
+// SetUserLimit gives userID its own capacity and refill rate, overriding its tier if
+// there is one. A user who is already tracked has the change applied to their
+// existing bucket at once, keeping the share of the capacity it holds, so an upgrade
+// from a half-full bucket leaves the larger bucket half full; a new user is created
+// with a full bucket of the given capacity. The limit is remembered, so a user who
+// is removed or evicted gets it back with their next bucket, until ClearUserLimit.
+// It returns an error for an invalid configuration, or errors.ErrUnsupported if the
+// user's limiter, built by a bucket factory, cannot be reconfigured. Re-applying a
+// bucket's current limit is logged at debug level only.
+func (rl *RatataLimiter) SetUserLimit(userID string, capacity int, refillRate time.Duration) error {
+	userID = rl.key(userID)
+	if err := rl.applyUserLimit(userID, capacity, refillRate); err != nil {
+		return err
+	}
+
+	rl.limitsMu.Lock()
+	defer rl.limitsMu.Unlock()
+
+	if rl.limits == nil {
+		rl.limits = make(map[string]TierConfig)
+	}
+	rl.limits[userID] = TierConfig{Capacity: capacity, RefillRate: refillRate}
+	return nil
+}
+
+// ClearUserLimit forgets the limit set with SetUserLimit for userID, so the user's
+// next bucket gets their tier or the limiter's own limit again. A tracked user keeps
+// their current bucket until it is removed or evicted.
+func (rl *RatataLimiter) ClearUserLimit(userID string) {
+	userID = rl.key(userID)
+
+	rl.limitsMu.Lock()
+	defer rl.limitsMu.Unlock()
+
+	delete(rl.limits, userID)
+}
+
+// userLimit returns the limit set with SetUserLimit for the already normalized
+// userID, if any.
+func (rl *RatataLimiter) userLimit(userID string) (TierConfig, bool) {
+	rl.limitsMu.Lock()
+	defer rl.limitsMu.Unlock()
+
+	limit, ok := rl.limits[userID]
+	return limit, ok
+}
+
+// applyUserLimit applies a limit to the already normalized userID's limiter, creating
+// the user if need be, without remembering it; see SetUserLimit.
+func (rl *RatataLimiter) applyUserLimit(userID string, capacity int, refillRate time.Duration) error {
+	if err := validateConfig(capacity, refillRate); err != nil {
+		return err
+	}
+
+	s := rl.shard(userID)
+	s.mu.Lock()
+	e, ok := s.users[userID]
+	if !ok {
+		e = rl.addUserLocked(s, userID, rl.buildUserLimiter(userID, capacity, refillRate), rl.opts.clock.Now())
+	}
+	s.mu.Unlock()
+	if !ok {
+		rl.enforceLimits(userID)
+	}
+
+	changed, err := setLimit(e.limiter, capacity, refillRate)
+	if err != nil {
+		return err
+	}
+	if !changed && ok {
+		// Callers such as AllowUserWithConfig re-apply the same limit on every call.
+		rl.opts.logger.Debugf("ratata: set limit of user %q to %d tokens, one per %s", userID, capacity, refillRate)
+		return nil
+	}
+	rl.opts.logger.Infof("ratata: set limit of user %q to %d tokens, one per %s", userID, capacity, refillRate)
+	return nil
+}
+
+// AllowUserWithConfig checks if an action is allowed for userID, like AllowUser,
+// after making sure the user's bucket has the given capacity and refill rate. It
+// suits callers that know each user's plan at call time: the first call creates the
+// bucket with that configuration and a call with a different one updates it in place
+// as SetUserLimit does, though the limit isn't remembered past the bucket since every
+// call supplies it. An invalid configuration is reported as an error, and the action
+// is then not allowed.
+func (rl *RatataLimiter) AllowUserWithConfig(userID string, capacity int, refillRate time.Duration) (bool, error) {
+	if err := rl.applyUserLimit(rl.key(userID), capacity, refillRate); err != nil {
+		return false, err
+	}
+	return rl.AllowUser(userID), nil
+}
+
+// setLimit reconfigures l if it supports it, and reports whether the limit changed.
+// Buckets keep their share of the capacity; see rescaleLimit. Other limiters can't
+// tell whether the limit was different and report false.
+func setLimit(l Limiter, capacity int, refillRate time.Duration) (changed bool, err error) {
+	if b, ok := l.(*RatataBucket); ok {
+		return b.rescaleLimit(capacity, refillRate)
+	}
+	s, ok := l.(interface {
+		SetLimit(capacity int, refillRate time.Duration) error
+	})
+	if !ok {
+		return false, errors.ErrUnsupported
+	}
+	return false, s.SetLimit(capacity, refillRate)
+}
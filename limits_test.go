@@ -0,0 +1,199 @@
+package ratata
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSetUserLimitMidStream(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(5, time.Second, WithClock(clock))
+	rl.AllowUser("alice") // 4 of 5 left.
+
+	if err := rl.SetUserLimit("alice", 2, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for rl.AllowUser("alice") {
+		n++
+	}
+	if n != 1 {
+		t.Errorf("admitted %d after shrinking a 4/5 bucket to 2, want 1 (its share of 80%%, rounded down)", n)
+	}
+}
+
+func TestSetUserLimitSurvivesEviction(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(5, time.Second, WithClock(clock), WithMaxUsers(1))
+	// admitted reports how many actions alice is allowed in a burst.
+	admitted := func() int {
+		n := 0
+		for rl.AllowUser("alice") {
+			n++
+		}
+		return n
+	}
+
+	if err := rl.SetUserLimit("alice", 2, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	rl.Remove("alice")
+	if got := admitted(); got != 2 {
+		t.Errorf("after Remove alice was admitted %d, want its own limit of 2", got)
+	}
+	rl.AllowUser("bob") // Evicts alice.
+	if got := admitted(); got != 2 {
+		t.Errorf("after eviction alice was admitted %d, want its own limit of 2", got)
+	}
+
+	rl.ClearUserLimit("alice")
+	rl.Remove("alice")
+	if got := admitted(); got != 5 {
+		t.Errorf("after ClearUserLimit alice was admitted %d, want the default of 5", got)
+	}
+}
+
+func TestAllowUserWithConfig(t *testing.T) {
+	rl := NewRatataLimiter(5, time.Second, WithClock(newFakeClock()))
+
+	if ok, err := rl.AllowUserWithConfig("bob", 1, 10*time.Second); !ok || err != nil {
+		t.Fatalf("first call = %v, %v; want allowed", ok, err)
+	}
+	if ok, err := rl.AllowUserWithConfig("bob", 1, 10*time.Second); ok || err != nil {
+		t.Errorf("second call = %v, %v; want denied by the 1-token bucket", ok, err)
+	}
+	if ok, err := rl.AllowUserWithConfig("bob", -1, time.Second); ok || !errors.Is(err, ErrInvalidCapacity) {
+		t.Errorf("negative capacity = %v, %v; want ErrInvalidCapacity", ok, err)
+	}
+	if err := rl.SetUserLimit("bob", 1, -1); !errors.Is(err, ErrInvalidRefillRate) {
+		t.Errorf("negative refill rate = %v, want ErrInvalidRefillRate", err)
+	}
+}
+
+func TestReconfigure(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(10, time.Second, WithClock(clock))
+	b.AllowN(4)
+	clock.Advance(1500 * time.Millisecond)
+
+	cfg := Config{Capacity: 5, RefillRate: 2 * time.Second, Reserve: 1}
+	if err := b.Reconfigure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	// The token earned under the old rate is kept before clamping to the new capacity.
+	if got := b.Balance(); got != 5 {
+		t.Errorf("Balance = %d, want 5", got)
+	}
+	if got := b.Tokens(); got != 4 {
+		t.Errorf("Tokens = %d, want 4 above the reserve", got)
+	}
+	if got, want := b.Config(), (Config{5, 2 * time.Second, 1, 1}); got != want {
+		t.Errorf("Config = %+v, want %+v", got, want)
+	}
+
+	if err := b.Reconfigure(Config{Capacity: -1, RefillRate: time.Second}); err == nil {
+		t.Error("Reconfigure accepted a negative capacity")
+	}
+	if got := b.Config().Capacity; got != 5 {
+		t.Errorf("a rejected Reconfigure changed the capacity to %d", got)
+	}
+}
+
+func TestReconfigureIsAtomic(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(10, time.Hour, WithClock(clock))
+	configs := []Config{
+		{Capacity: 10, RefillRate: time.Hour, RateMultiplier: 1},
+		{Capacity: 20, RefillRate: 2 * time.Hour, RateMultiplier: 2},
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if err := b.Reconfigure(configs[i%2]); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	var allowed atomic.Int32
+	var readers sync.WaitGroup
+	for range 4 {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for range 2000 {
+				if cfg := b.Config(); cfg != configs[0] && cfg != configs[1] {
+					t.Errorf("Config = %+v, a mix of the two configurations", cfg)
+					return
+				}
+				if b.Allow() {
+					allowed.Add(1)
+				}
+			}
+		}()
+	}
+	readers.Wait()
+	close(stop)
+	wg.Wait()
+
+	// The clock never moves and a larger capacity isn't granted outright, so only the
+	// 10 tokens the bucket started with can be spent.
+	if got := allowed.Load(); got != 10 {
+		t.Errorf("admitted %d actions, want 10", got)
+	}
+}
+
+func TestConfigEqual(t *testing.T) {
+	b := NewRatataBucket(5, time.Second)
+	cfg := b.Config()
+	if !cfg.Equal(Config{Capacity: 5, RefillRate: time.Second}) {
+		t.Errorf("Config = %+v, want the constructor's 5 tokens, one per second", cfg)
+	}
+	if cfg.Equal(Config{Capacity: 5, RefillRate: 2 * time.Second}) {
+		t.Error("Equal ignores a different refill rate")
+	}
+
+	if err := b.Reconfigure(Config{Capacity: 6, RefillRate: time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Equal(b.Config()) {
+		t.Error("Equal ignores a different capacity")
+	}
+	if cfg.Capacity != 5 {
+		t.Errorf("Reconfigure changed an earlier Config to capacity %d", cfg.Capacity)
+	}
+}
+
+func TestSetUserLimitLogsChangesOnly(t *testing.T) {
+	logger := &recordLogger{}
+	rl := NewRatataLimiter(5, time.Second, WithLogger(logger))
+
+	for range 3 {
+		if _, err := rl.AllowUserWithConfig("alice", 10, time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := logger.count("INFO"); got != 1 {
+		t.Errorf("got %d info lines after re-applying the same limit, want 1: %q", got, logger.lines)
+	}
+
+	if err := rl.SetUserLimit("alice", 20, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if got := logger.count("INFO"); got != 2 {
+		t.Errorf("got %d info lines after changing the limit, want 2: %q", got, logger.lines)
+	}
+}
@@ -0,0 +1,20 @@
+package ratata
+
+// Logger receives messages about significant events in a RatataLimiter, such as
+// evictions, blocks and reconfiguration, so they can be logged without this package
+// depending on a logging library. Adapting a logger such as log/slog takes a few
+// lines. Methods may be called while the limiter holds internal locks, so they must
+// not call back into the limiter.
+type Logger interface {
+	Debugf(format string, args ...any) // Debugf logs a frequent, routine event.
+	Infof(format string, args ...any)  // Infof logs an event an operator may care about.
+}
+
+// nopLogger is the default Logger, which discards everything.
+type nopLogger struct{}
+
+// Debugf discards the message.
+func (nopLogger) Debugf(string, ...any) {}
+
+// Infof discards the message.
+func (nopLogger) Infof(string, ...any) {}
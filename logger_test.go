@@ -0,0 +1,67 @@
+package ratata
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordLogger is a Logger that keeps every line, prefixed with its level.
+type recordLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordLogger) Debugf(format string, args ...any) { l.add("DEBUG ", format, args) }
+func (l *recordLogger) Infof(format string, args ...any)  { l.add("INFO ", format, args) }
+
+func (l *recordLogger) add(level, format string, args []any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, level+fmt.Sprintf(format, args...))
+}
+
+// count returns the number of lines logged at level.
+func (l *recordLogger) count(level string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, line := range l.lines {
+		if strings.HasPrefix(line, level+" ") {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLoggerEvictionAndBlock(t *testing.T) {
+	logger := &recordLogger{}
+	clock := newFakeClock()
+	rl := NewRatataLimiter(1, time.Hour, WithClock(clock), WithLogger(logger), WithMaxUsers(1),
+		WithAutoBlock(2, time.Minute, time.Second))
+
+	rl.AllowUser("alice")
+	clock.Advance(time.Nanosecond)
+	rl.AllowUser("bob") // Evicts alice.
+	rl.AllowUser("bob")
+	rl.AllowUser("bob") // Second denial: blocks bob.
+	clock.Advance(2 * time.Second)
+	rl.AllowUser("bob") // Lifts the expired block.
+
+	want := []string{
+		`DEBUG ratata: evicted user "alice", last seen `,
+		`INFO ratata: user "bob" reached 2 denials within 1m0s`,
+		`INFO ratata: blocked user "bob" for 1s`,
+		`DEBUG ratata: unblocked user "bob"`,
+	}
+	if len(logger.lines) != len(want) {
+		t.Fatalf("got %d lines, want %d: %q", len(logger.lines), len(want), logger.lines)
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(logger.lines[i], prefix) {
+			t.Errorf("line %d = %q, want prefix %q", i, logger.lines[i], prefix)
+		}
+	}
+}
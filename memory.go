@@ -0,0 +1,42 @@
+package ratata
+
+import (
+	"reflect"
+	"time"
+	"unsafe"
+)
+
+// mapSlotBytes approximates what a user costs the user map itself: the slot holding
+// the key's string header and the entry pointer, plus the control byte and the
+// unused share of the map's groups.
+const mapSlotBytes = 40
+
+// EstimateUserBytes returns the approximate number of bytes the limiter spends on
+// tracking userID with the given metadata attached: the map slot, the user's entry
+// and a RatataBucket, the ID itself, any audit history, and the metadata. A string
+// or []byte of metadata counts with its length; any other value counts the size of
+// its type only, not memory it points to. The estimate ignores allocator rounding
+// and the size of limiters built by a bucket factory, so it is a planning figure
+// rather than an exact measurement.
+func (rl *RatataLimiter) EstimateUserBytes(userID string, meta any) int {
+	n := mapSlotBytes + int(unsafe.Sizeof(userEntry{})+unsafe.Sizeof(RatataBucket{})) + len(userID)
+	if rl.opts.auditSize > 0 {
+		n += int(unsafe.Sizeof(ring[time.Time]{})) + rl.opts.auditSize*int(unsafe.Sizeof(time.Time{}))
+	}
+	switch m := meta.(type) {
+	case nil:
+	case string:
+		n += len(m)
+	case []byte:
+		n += len(m)
+	default:
+		n += int(reflect.TypeOf(meta).Size())
+	}
+	return n
+}
+
+// MemoryBytes returns the approximate number of bytes the limiter spends on all of
+// its tracked users, the sum of EstimateUserBytes over them.
+func (rl *RatataLimiter) MemoryBytes() int {
+	return int(rl.memory.Load())
+}
@@ -0,0 +1,50 @@
+package ratata
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaxMemoryBytesEvictsForMeta(t *testing.T) {
+	clock := newFakeClock()
+	per := NewRatataLimiter(1, time.Second).EstimateUserBytes("u0", nil)
+	rl := NewRatataLimiter(1, time.Second, WithClock(clock), WithMaxMemoryBytes(10*per))
+	for _, id := range []string{"u0", "u1", "u2", "u3", "u4"} {
+		clock.Advance(time.Second)
+		rl.AllowUser(id)
+	}
+	if users, bytes := rl.CountTotalUsers(), rl.MemoryBytes(); users != 5 || bytes != 5*per {
+		t.Fatalf("got %d users in %d bytes, want 5 in %d", users, bytes, 5*per)
+	}
+
+	rl.SetUserMeta("u4", strings.Repeat("x", 3*per))
+	if got := rl.CountTotalUsers(); got != 5 {
+		t.Errorf("metadata within the budget evicted users, %d left", got)
+	}
+
+	// Another 3 buckets' worth of metadata exceeds the budget of 10 by one bucket.
+	clock.Advance(time.Second)
+	rl.SetUserMeta("u3", strings.Repeat("x", 3*per))
+	if users, bytes := rl.CountTotalUsers(), rl.MemoryBytes(); users != 4 || bytes != 10*per {
+		t.Errorf("got %d users in %d bytes, want 4 in %d", users, bytes, 10*per)
+	}
+	if _, ok := rl.UserMeta("u0"); ok {
+		t.Error("the least recently used user u0 wasn't evicted")
+	}
+
+	rl.AllowUser("u5")
+	if _, ok := rl.UserMeta("u1"); ok || rl.CountTotalUsers() != 4 {
+		t.Error("a new user didn't evict the next least recently used, u1")
+	}
+}
+
+func TestMaxMemoryBytesKeepsOneUser(t *testing.T) {
+	rl := NewRatataLimiter(1, time.Second, WithMaxMemoryBytes(1))
+	rl.AllowUser("alice")
+	rl.SetUserMeta("alice", 1)
+	rl.AllowUser("bob")
+	if got := rl.CountTotalUsers(); got != 1 {
+		t.Errorf("got %d users under a budget smaller than one, want 1", got)
+	}
+}
@@ -0,0 +1,90 @@
+package ratata
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store that keeps buckets in process memory. A RatataLimiter
+// without a Store already keeps its users in memory, so MemoryStore is mainly a
+// reference implementation of the Store contract and a stand-in for a shared store
+// in tests, or the backend of a LeaseStore within one process. Buckets refill with
+// floor rounding and are never evicted.
+type MemoryStore struct {
+	buckets map[string]*storedBucket // Buckets by key.
+	mu      sync.Mutex               // Mutex to protect buckets.
+}
+
+// storedBucket is the state a MemoryStore keeps for one key.
+type storedBucket struct {
+	tokens     int       // Tokens in the bucket as of lastRefill.
+	lastRefill time.Time // Time refill was last credited.
+}
+
+var (
+	_ Store    = (*MemoryStore)(nil)
+	_ Refunder = (*MemoryStore)(nil)
+)
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*storedBucket)}
+}
+
+// Take refills the bucket under req.Key as of req.Now and consumes req.N tokens if
+// they are available, creating a full bucket for a new key.
+func (ms *MemoryStore) Take(ctx context.Context, req TakeRequest) (TakeResult, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	b := ms.refill(req)
+	allowed := req.N <= 0 || (req.Capacity > 0 && b.tokens >= req.N)
+	if allowed {
+		b.tokens -= max(req.N, 0)
+	}
+	return TakeResult{Allowed: allowed, Remaining: b.tokens, RetryAfter: b.delay(req, max(req.N, 1))}, nil
+}
+
+// Refund adds req.N tokens back to the bucket under req.Key, up to req.Capacity.
+func (ms *MemoryStore) Refund(ctx context.Context, req TakeRequest) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	b := ms.refill(req)
+	b.tokens = min(b.tokens+max(req.N, 0), req.Capacity)
+	return nil
+}
+
+// refill returns the bucket under req.Key, refilled as of req.Now. The caller must
+// hold ms.mu.
+func (ms *MemoryStore) refill(req TakeRequest) *storedBucket {
+	b, ok := ms.buckets[req.Key]
+	if !ok {
+		b = &storedBucket{tokens: req.Capacity, lastRefill: req.Now}
+		ms.buckets[req.Key] = b
+	}
+	b.tokens = min(b.tokens, req.Capacity) // The capacity may have been lowered.
+
+	elapsed := req.Now.Sub(b.lastRefill)
+	if elapsed <= 0 || req.RefillRate <= 0 {
+		return b
+	}
+	if earned := int(elapsed / req.RefillRate); earned >= req.Capacity-b.tokens {
+		b.tokens, b.lastRefill = req.Capacity, req.Now // A full bucket doesn't bank time.
+	} else {
+		b.tokens += earned
+		b.lastRefill = b.lastRefill.Add(time.Duration(earned) * req.RefillRate)
+	}
+	return b
+}
+
+// delay returns how long after req.Now the bucket will hold n tokens, or zero if it
+// already does.
+func (b *storedBucket) delay(req TakeRequest, n int) time.Duration {
+	missing := n - b.tokens
+	if missing <= 0 {
+		return 0
+	}
+	return max(b.lastRefill.Add(time.Duration(missing)*req.RefillRate).Sub(req.Now), 0)
+}
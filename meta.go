@@ -0,0 +1,87 @@
+package ratata
+
+import "time"
+
+// UserInfo describes a user tracked by a RatataLimiter, as passed to ForEachUser.
+type UserInfo struct {
+	UserID     string    // ID of the user.
+	Limiter    Limiter   // The user's rate limiter, usually a *RatataBucket.
+	LastAccess time.Time // Time of the user's last admission check.
+	Meta       any       // Metadata attached with SetUserMeta, or nil.
+}
+
+// SetUserMeta attaches opaque metadata, such as a plan name, region or owner, to
+// userID, replacing any set before. It is delivered in the Meta field of each Result
+// for the user, including those passed to the hook set with WithOnDecision, and in
+// ForEachUser, so integrations can label or route decisions without a lookup of
+// their own. A user who isn't tracked yet is created with a full bucket. The
+// metadata lives as long as the user is tracked and is dropped on eviction.
+func (rl *RatataLimiter) SetUserMeta(userID string, meta any) {
+	userID = rl.key(userID)
+	s := rl.shard(userID)
+
+	s.mu.Lock()
+	e, ok := s.users[userID]
+	if !ok {
+		e = rl.addUserLocked(s, userID, rl.newUserLimiter(userID), rl.opts.clock.Now())
+	}
+	e.meta = meta
+	rl.hasMeta.Store(true)
+
+	bytes := rl.EstimateUserBytes(userID, meta)
+	rl.memory.Add(int64(bytes - e.bytes))
+	e.bytes = bytes
+	s.mu.Unlock()
+
+	rl.enforceLimits(userID)
+}
+
+// UserMeta returns the metadata attached to userID with SetUserMeta, and whether the
+// user is tracked. It doesn't create the user or count as an access.
+func (rl *RatataLimiter) UserMeta(userID string) (meta any, ok bool) {
+	userID = rl.key(userID)
+	s := rl.shard(userID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.users[userID]
+	if !ok {
+		return nil, false
+	}
+	return e.meta, true
+}
+
+// userMeta returns the metadata of the already normalized userID, skipping the lock
+// while no metadata has ever been set.
+func (rl *RatataLimiter) userMeta(userID string) any {
+	if !rl.hasMeta.Load() {
+		return nil
+	}
+
+	s := rl.shard(userID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.users[userID]; ok {
+		return e.meta
+	}
+	return nil
+}
+
+// ForEachUser calls fn for each tracked user, in no particular order, until fn
+// returns false. The users are collected first and fn is called without holding the
+// limiter's locks, so fn may call back into the limiter; users added or evicted
+// meanwhile may or may not be visited.
+func (rl *RatataLimiter) ForEachUser(fn func(UserInfo) bool) {
+	users := make([]UserInfo, 0, rl.CountTotalUsers())
+	rl.rangeUsers(func(id string, e *userEntry) {
+		users = append(users, UserInfo{UserID: id, Limiter: e.limiter, LastAccess: e.lastAccess, Meta: e.meta})
+	})
+
+	for _, u := range users {
+		if !fn(u) {
+			return
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package ratata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUserMetaInDecisions(t *testing.T) {
+	var got []Result
+	rl := NewRatataLimiter(1, time.Hour, WithOnDecision(func(res Result) { got = append(got, res) }))
+	rl.SetUserMeta("alice", "pro")
+	rl.AllowUser("alice")
+	rl.AllowUser("alice")
+
+	if len(got) != 2 {
+		t.Fatalf("got %d decisions, want 2", len(got))
+	}
+	for i, res := range got {
+		if res.Meta != "pro" {
+			t.Errorf("decision %d has Meta %v, want pro", i, res.Meta)
+		}
+	}
+	if got[1].Allowed {
+		t.Error("second decision was allowed")
+	}
+
+	var users int
+	rl.ForEachUser(func(u UserInfo) bool {
+		users++
+		if u.Meta != "pro" {
+			t.Errorf("ForEachUser reports Meta %v for %s, want pro", u.Meta, u.UserID)
+		}
+		return true
+	})
+	if users != 1 {
+		t.Errorf("ForEachUser visited %d users, want 1", users)
+	}
+}
+
+func TestUserMetaClearedOnEviction(t *testing.T) {
+	var got []Result
+	rl := NewRatataLimiter(1, time.Hour, WithMaxUsers(1), WithOnDecision(func(res Result) { got = append(got, res) }))
+	rl.SetUserMeta("alice", "pro")
+	rl.AllowUser("bob") // Evicts alice.
+
+	if meta, ok := rl.UserMeta("alice"); ok {
+		t.Errorf("UserMeta of an evicted user = %v, want none", meta)
+	}
+	rl.AllowUser("alice")
+	if meta := got[len(got)-1].Meta; meta != nil {
+		t.Errorf("alice's new bucket has Meta %v, want nil", meta)
+	}
+}
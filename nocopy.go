@@ -0,0 +1,13 @@
+package ratata
+
+// noCopy may be embedded in structs that must not be copied after first use.
+// go vet's copylocks check reports any value copy of a struct containing it.
+//
+// See https://golang.org/issues/8005#issuecomment-190753527 for details.
+type noCopy struct{}
+
+// Lock is a no-op used by the copylocks checker.
+func (*noCopy) Lock() {}
+
+// Unlock is a no-op used by the copylocks checker.
+func (*noCopy) Unlock() {}
@@ -0,0 +1,57 @@
+package ratata
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestVetReportsCopies runs go vet on a module that copies a RatataBucket and a
+// RatataLimiter by value, and checks that the copylocks check reports both.
+func TestVetReportsCopies(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go vet")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	root, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module copytest\n\ngo 1.23\n\nrequire github.com/vsheshjain/ratata v0.0.0\n\n" +
+			"replace github.com/vsheshjain/ratata => " + root + "\n",
+		"copy.go": `package copytest
+
+import "github.com/vsheshjain/ratata"
+
+func CopyBucket(b *ratata.RatataBucket) ratata.RatataBucket { return *b }
+
+func CopyLimiter(rl *ratata.RatataLimiter) ratata.RatataLimiter { return *rl }
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(goTool, "vet", "./...")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=-mod=mod")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("go vet accepted copies of a RatataBucket and a RatataLimiter:\n%s", out)
+	}
+	for _, want := range []string{"ratata.RatataBucket contains", "ratata.RatataLimiter contains"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("go vet output doesn't mention %q:\n%s", want, out)
+		}
+	}
+}
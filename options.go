@@ -0,0 +1,418 @@
+package ratata
+
+import "time"
+
+// Option configures optional behavior of a RatataBucket or RatataLimiter.
+type Option func(*options)
+
+// options holds the optional settings applied when a bucket is created.
+type options struct {
+	clock     Clock        // Source of time for refill calculations.
+	logger    Logger       // Receives messages about significant events.
+	tracer    Tracer       // Traces blocking waits, if set.
+	rounding  RoundingMode // How partial refill intervals are rounded.
+	algorithm Algorithm    // Algorithm of limiters built by NewLimiter and of RatataLimiter users.
+	overdraft bool         // Whether consumption may drive the balance below zero.
+	partial   bool         // Whether AllowN may consume fewer tokens than requested.
+	reserve   int          // Tokens only AllowPriority may consume.
+	welcome   int          // One-time tokens a new bucket gets above its capacity.
+
+	waitAttempts   int           // Re-checks Wait makes before giving up; negative means no limit.
+	tickerInterval time.Duration // Interval of background refill; zero means lazy refill.
+	refillFunc     RefillFunc    // Decides how many tokens to add, if set.
+	perInterval    time.Duration // Tokens earned per refill rate, as a count; see WithTokensPerInterval.
+
+	factory    func(userID string) Limiter // Builds each user's limiter, if set.
+	tiers      TierResolver                // Picks the limit of each new user's bucket, if set.
+	autoBlock  autoBlock                   // Automatic blocking of users with many denials.
+	maxUsers   int                         // Maximum number of tracked users; zero means no limit.
+	maxBytes   int                         // Approximate memory budget for users; zero means no limit.
+	idleTTL    time.Duration               // How long a full bucket may sit idle before eviction; zero disables.
+	onRecover  func(userID string)         // Called when a user's empty bucket gets a token back.
+	normalize  func(userID string) string  // Canonicalizes user IDs, if set.
+	onDecision func(Result)                // Called with every decision, if set.
+	statsSink  func(Stats)                 // Receives the final stats when the limiter stops, if set.
+
+	historySize int // Number of recent decisions to keep; zero disables the history.
+	auditSize   int // Number of allowed action times to keep per user; zero disables.
+
+	store    Store // External store holding per-user state, if set.
+	failOpen bool  // Whether to allow actions when the store fails.
+
+	globalLimit globalConfig // Limit shared by all users of a RatataLimiter.
+}
+
+// globalConfig holds the settings of WithGlobalLimit.
+type globalConfig struct {
+	capacity   int           // Capacity of the global bucket; zero disables it.
+	refillRate time.Duration // Refill rate of the global bucket.
+}
+
+// autoBlock holds the settings of WithAutoBlock.
+type autoBlock struct {
+	threshold int           // Denials within window that trigger a block; zero disables.
+	window    time.Duration // Length of the window denials are counted in.
+	cooldown  time.Duration // How long a triggered block lasts.
+}
+
+// defaultOptions returns the settings used when no Option is supplied.
+func defaultOptions() options {
+	return options{
+		clock:        systemClock{},
+		logger:       nopLogger{},
+		perInterval:  1,
+		waitAttempts: -1,
+	}
+}
+
+// newOptions applies opts on top of the defaults.
+func newOptions(opts []Option) options {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithClock sets the Clock used to measure elapsed time for refills.
+// A nil clock is ignored and the system clock is kept.
+func WithClock(c Clock) Option {
+	return func(o *options) {
+		if c != nil {
+			o.clock = c
+		}
+	}
+}
+
+// WithLogger sets the Logger a RatataLimiter reports evictions, blocks and
+// reconfiguration to. By default nothing is logged. A nil logger is ignored.
+func WithLogger(l Logger) Option {
+	return func(o *options) {
+		if l != nil {
+			o.logger = l
+		}
+	}
+}
+
+// WithTracer makes blocking waits report a span to t, recording how long callers
+// blocked waiting for tokens and whether they got them, so that rate-limit-induced
+// latency shows up in distributed traces. It covers Wait and WaitN of a RatataBucket,
+// including those made by RateLimitedReader and RateLimitedWriter, and AllowUserN of
+// a RatataLimiter. The ratataotel package adapts an OpenTelemetry tracer. A nil
+// tracer disables tracing, which is the default.
+func WithTracer(t Tracer) Option {
+	return func(o *options) {
+		o.tracer = t
+	}
+}
+
+// WithRoundingMode sets how partial refill intervals are rounded into tokens.
+// The default is RoundFloor. See RoundingMode for how each mode behaves.
+func WithRoundingMode(mode RoundingMode) Option {
+	return func(o *options) {
+		o.rounding = mode
+	}
+}
+
+// WithTokensPerInterval makes every refill rate given to the bucket or limiter the
+// interval in which n tokens are earned, rather than the time to earn one, to express
+// refill as "n tokens every interval": NewRatataBucket(10, 2*time.Second,
+// WithTokensPerInterval(5)) earns 5 tokens every 2 seconds. Tokens still accrue one
+// at a time, each after interval/n, and the division is exact even where interval/n
+// isn't a whole number of nanoseconds, so no time is lost to rounding in the long
+// run. The lock-free global Allow path rounds its per-token interval to the
+// nanosecond, and so does the refill rate passed to a Store. Zero or less means 1.
+func WithTokensPerInterval(n int) Option {
+	return func(o *options) {
+		o.perInterval = time.Duration(max(n, 1))
+	}
+}
+
+// WithOverdraft makes AllowN always succeed, driving the balance below zero when the
+// bucket runs out instead of denying. This suits metered billing, where overage is
+// charged rather than blocked: the negative Balance is the billable overage, and
+// refill gradually brings the balance back up. Since Allow then always succeeds,
+// Peek never reports a denial and IsEmpty never reports the bucket as empty; use
+// Balance or Tokens to tell how far it is overdrawn.
+func WithOverdraft() Option {
+	return func(o *options) {
+		o.overdraft = true
+	}
+}
+
+// WithPartialConsume makes AllowN, ConsumeN and Charge of a RatataBucket consume as
+// many of the requested tokens as are available instead of all or nothing, for
+// metering where partial progress is acceptable and the caller charges for exactly
+// what it got. ConsumeN reports the number consumed. The per-user decisions of a
+// RatataLimiter stay all or nothing.
+func WithPartialConsume() Option {
+	return func(o *options) {
+		o.partial = true
+	}
+}
+
+// WithBucketFactory makes a RatataLimiter create each new user's limiter by calling
+// factory instead of copying its own capacity and refill rate. This allows per-user
+// configuration or a different algorithm per user. The factory is called while the
+// limiter's user map is locked, so it must not call back into the limiter. If it
+// returns nil, the user gets a bucket with the limiter's own settings.
+func WithBucketFactory(factory func(userID string) Limiter) Option {
+	return func(o *options) {
+		o.factory = factory
+	}
+}
+
+// WithAlgorithm selects the algorithm of the limiters built by NewLimiter and of each
+// new user's limiter in a RatataLimiter, TokenBucket by default. GCRA and
+// SlidingWindow limiters take the same capacity and refill rate as a bucket and honor
+// per-user limits and tiers, but features specific to RatataBucket, such as Wait's
+// exact delays, reserves, overdraft, degraded mode and snapshots, treat them as they
+// do limiters built by a bucket factory. The global limit of WithGlobalLimit is
+// always a token bucket.
+func WithAlgorithm(a Algorithm) Option {
+	return func(o *options) {
+		o.algorithm = a
+	}
+}
+
+// WithTierResolver makes a RatataLimiter create each new user's bucket with the
+// capacity and refill rate of the tier resolve returns for the user, so free, pro and
+// enterprise users can share one limiter. Users it returns false for, or an invalid
+// tier for, get the limiter's own settings, and a bucket factory takes precedence.
+// Like a factory, resolve is called while the user map is locked and must not call
+// back into the limiter. Moving a tracked user to another tier is done with
+// SetUserLimit.
+func WithTierResolver(resolve TierResolver) Option {
+	return func(o *options) {
+		o.tiers = resolve
+	}
+}
+
+// RefillFunc decides how many tokens to add to bucket as of now; see WithRefillFunc.
+type RefillFunc func(now time.Time, bucket *RatataBucket) int
+
+// WithRefillFunc makes a bucket refill from fn instead of from elapsed time, for
+// tokens tied to an external signal, such as credits granted by a billing service.
+// Whenever the bucket would normally refill, fn is called with the current time and
+// the bucket, and the tokens it returns are added, up to the capacity; zero or less
+// adds nothing. It isn't called while the bucket is full, so no credits are drawn
+// that the bucket couldn't hold. fn runs with the bucket locked, so it must not call
+// the bucket's methods; the bucket is passed only to tell buckets apart. Wait and
+// retry delays are still estimated from the refill rate.
+func WithRefillFunc(fn RefillFunc) Option {
+	return func(o *options) {
+		o.refillFunc = fn
+	}
+}
+
+// WithWaitMaxAttempts bounds Wait by a number of refill-wait cycles instead of, or
+// in addition to, a context deadline. After the initial check fails, Wait sleeps
+// until the missing tokens should have been refilled and checks again, at most n
+// times, before returning ErrWaitAttemptsExceeded. With n == 0, Wait makes a single
+// non-blocking attempt. A negative n removes the limit, which is the default.
+func WithWaitMaxAttempts(n int) Option {
+	return func(o *options) {
+		o.waitAttempts = n
+	}
+}
+
+// WithAutoBlock makes a RatataLimiter block users that are denied threshold times
+// within window, for the duration of cooldown, shedding load from abusive clients.
+// Blocked users are denied without consuming tokens and become eligible again once
+// the cooldown ends. Only denials for an empty bucket count; those by the limit set
+// with WithGlobalLimit or held back by SetDegraded don't, as they aren't caused by
+// the user's own rate. A non-positive threshold disables auto-blocking.
+func WithAutoBlock(threshold int, window, cooldown time.Duration) Option {
+	return func(o *options) {
+		o.autoBlock = autoBlock{threshold: threshold, window: window, cooldown: cooldown}
+	}
+}
+
+// WithTickerRefill makes a bucket created with NewRatataBucket refill from a
+// background ticker every interval instead of lazily on each call. Tokens, Peek
+// and the other queries then just read the current state, which helps when a
+// rarely used bucket is polled often, e.g. by a dashboard. Tokens are credited with
+// the same arithmetic in both modes, so long-run admissions match; in ticker mode a
+// token may just become visible up to one interval late. Call Stop to halt the
+// ticker goroutine.
+//
+// The option has no effect on the per-user buckets of a RatataLimiter, which always
+// refill lazily so that tracking many users doesn't start a goroutine for each.
+func WithTickerRefill(interval time.Duration) Option {
+	return func(o *options) {
+		o.tickerInterval = interval
+	}
+}
+
+// WithReserve keeps floor tokens of the bucket out of reach of ordinary traffic.
+// Allow, AllowN and Wait only consume tokens above the floor, and Tokens, Peek and
+// IsEmpty report on those tokens alone, while AllowPriority can also consume the
+// reserve. Refill still fills the bucket to its full capacity, so a depleted
+// reserve is restored before ordinary traffic gets tokens again.
+func WithReserve(floor int) Option {
+	return func(o *options) {
+		o.reserve = max(floor, 0)
+	}
+}
+
+// WithWelcomeBurst gives each new bucket, such as a brand-new user's, extra tokens
+// on top of its capacity that are never refilled once spent: the bucket starts with
+// capacity+extra tokens, but refill only tops it up to its capacity. Until the extra
+// is spent, Tokens may report more than the capacity. Changing the bucket's
+// capacity clamps away what is left of the extra, as does Restore. A bucket with a
+// capacity of zero gets no extra.
+func WithWelcomeBurst(extra int) Option {
+	return func(o *options) {
+		o.welcome = max(extra, 0)
+	}
+}
+
+// WithMaxUsers caps the number of users a RatataLimiter tracks. When a new user
+// would exceed the cap, the users whose last admission check is oldest are evicted
+// first, ties going to the smaller user ID, so eviction is deterministic. An evicted
+// user starts over with a full bucket if seen again. The new user is added before
+// the oldest is evicted, so concurrent first calls may briefly take the count past
+// the cap. Zero or less means no cap.
+func WithMaxUsers(n int) Option {
+	return func(o *options) {
+		o.maxUsers = n
+	}
+}
+
+// WithMaxMemoryBytes caps the approximate memory a RatataLimiter spends on tracked
+// users at n bytes, for capacity planning in terms of memory rather than a user
+// count. Each user's footprint is estimated as described for EstimateUserBytes,
+// including any metadata and audit history, and when adding a user or attaching
+// metadata would exceed the budget, the users whose last admission check is oldest
+// are evicted first, as with WithMaxUsers; the two caps can be combined. A single
+// user larger than the budget is still tracked. Zero or less means no cap.
+func WithMaxMemoryBytes(n int) Option {
+	return func(o *options) {
+		o.maxBytes = n
+	}
+}
+
+// WithIdleTTL makes a RatataLimiter forget users whose bucket is full and who have
+// had no admission check for ttl, so that a flood of one-off user IDs, such as from
+// a scraper, can't grow the user map without bound. A full bucket is what an
+// unknown user starts with, so evicting one changes no decision; only its metadata,
+// audit history and scheduled reset are lost. A background janitor sweeps the users
+// every ttl, until Close is called; EvictIdle sweeps on demand. Zero or less
+// disables the janitor, which is the default.
+func WithIdleTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.idleTTL = ttl
+	}
+}
+
+// WithOnRecover registers a hook that a RatataLimiter calls when refill moves a
+// user's bucket from having no tokens available to having at least one, so the user
+// can be told they may act again. Because refill is lazy, recovery is only observed
+// when the bucket is next accessed, not at the instant the token is earned, and a
+// user who is never accessed again never triggers the hook. The hook runs while the
+// user's bucket is locked, so it must be quick and must not call back into the
+// limiter for the same user.
+func WithOnRecover(hook func(userID string)) Option {
+	return func(o *options) {
+		o.onRecover = hook
+	}
+}
+
+// WithKeyNormalizer makes a RatataLimiter pass every user ID it is given through
+// normalize before using it, for example to lowercase emails or canonicalize IP
+// addresses, so that one logical user never ends up split across several buckets.
+// It applies to every method that takes a user ID, and the normalized ID is what the
+// limiter reports, such as in a Result or a Snapshot. It is applied once per call.
+func WithKeyNormalizer(normalize func(userID string) string) Option {
+	return func(o *options) {
+		o.normalize = normalize
+	}
+}
+
+// WithOnDecision registers a hook that a RatataLimiter calls with every admission
+// decision it makes, allowed or denied, for logging, metrics or routing. Per-user
+// decisions carry the user's Key and any metadata set with SetUserMeta; decisions of
+// the global Allow path have an empty Key. The hook runs synchronously on the
+// caller's goroutine, after the decision is made and without the limiter's locks
+// held, so it should be quick. The option may be given several times, such as once
+// for logging and once for a metrics collector; the hooks are called in order.
+func WithOnDecision(hook func(Result)) Option {
+	return func(o *options) {
+		if prev := o.onDecision; prev != nil && hook != nil {
+			o.onDecision = func(res Result) {
+				prev(res)
+				hook(res)
+			}
+			return
+		}
+		if hook != nil {
+			o.onDecision = hook
+		}
+	}
+}
+
+// WithStatsSink makes RatataLimiter.Stop deliver the limiter's final Stats to sink,
+// so that a service shutting down doesn't lose the counts accumulated since its
+// metrics were last scraped. Per-user state can be read from within sink with
+// ForEachUser.
+func WithStatsSink(sink func(Stats)) Option {
+	return func(o *options) {
+		o.statsSink = sink
+	}
+}
+
+// WithRecentHistory makes a RatataLimiter keep its last n decisions across all
+// users in a fixed-size ring buffer, for a quick look at recent throttling through
+// RecentDecisions without wiring up a log. Once full, each new decision overwrites
+// the oldest one. Zero or less disables the history, which is the default.
+func WithRecentHistory(n int) Option {
+	return func(o *options) {
+		o.historySize = n
+	}
+}
+
+// WithAuditHistory makes a RatataLimiter keep the times of each user's last n
+// allowed actions, for audit logs that need to show after the fact when a user was
+// permitted to act. UserAuditLog returns them. A user's history is dropped along
+// with the user on eviction, and isn't kept for buckets in a Store. Zero or less
+// disables the audit history, which is the default.
+func WithAuditHistory(n int) Option {
+	return func(o *options) {
+		o.auditSize = n
+	}
+}
+
+// WithStore makes a RatataLimiter keep per-user bucket state in store instead of in
+// memory, so that several limiter instances can enforce one shared limit. Blocks and
+// auto-blocking still apply per instance.
+func WithStore(store Store) Option {
+	return func(o *options) {
+		o.store = store
+	}
+}
+
+// WithFailOpen sets what a RatataLimiter with a Store decides when the store fails
+// or the context ends before it answers: allow the action (fail open) or deny it
+// (fail closed). Failing open keeps traffic flowing through a store outage at the
+// cost of not limiting it; failing closed, the default, protects the backend but
+// turns a store outage into an outage of everything behind the limiter.
+func WithFailOpen(failOpen bool) Option {
+	return func(o *options) {
+		o.failOpen = failOpen
+	}
+}
+
+// WithGlobalLimit adds a limit shared by all users of a RatataLimiter on top of each
+// user's own bucket, e.g. 10 requests a second per user and 2000 in total. AllowUser
+// then only allows an action if both the user's bucket and the global bucket have a
+// token; if the global bucket denies, the user's token is returned, as described for
+// Chain, so the denial doesn't count against either level. When the global limit is
+// the binding constraint, its tokens are shared fairly among the users competing for
+// them, so one noisy user cannot monopolize the global budget. The global limit
+// applies to in-memory buckets, not to a Store. Bucket options such as WithOverdraft,
+// WithReserve or WithWelcomeBurst apply only to the users' buckets.
+func WithGlobalLimit(capacity int, refillRate time.Duration) Option {
+	return func(o *options) {
+		o.globalLimit = globalConfig{capacity: capacity, refillRate: refillRate}
+	}
+}
@@ -0,0 +1,27 @@
+package ratata
+
+import (
+	"math"
+	"time"
+)
+
+// RecommendConfig returns a bucket configuration that sustains targetRPS actions a
+// second and absorbs bursts of up to burstSeconds worth of that rate on top, for use
+// with NewRatataBucket or Reconfigure.
+//
+// The model is the token bucket's own: refill adds one token every 1/targetRPS
+// seconds, which is the long-run admission rate, and the capacity of
+// targetRPS*burstSeconds tokens, rounded up, is how many actions an idle client can
+// fire at once before being held to that rate. The capacity is at least one token,
+// so a burst tolerance of zero means strict pacing. The refill rate is rounded to
+// the nanosecond, so very high rates are approximate. A non-positive targetRPS
+// yields the zero Config, which is invalid.
+func RecommendConfig(targetRPS float64, burstSeconds float64) Config {
+	if !(targetRPS > 0) {
+		return Config{}
+	}
+	return Config{
+		Capacity:   max(int(math.Ceil(targetRPS*max(burstSeconds, 0))), 1),
+		RefillRate: max(time.Duration(float64(time.Second)/targetRPS), 1),
+	}
+}
@@ -0,0 +1,38 @@
+package ratata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecommendConfig(t *testing.T) {
+	cfg := RecommendConfig(50, 2)
+	if cfg.Capacity != 100 || cfg.RefillRate != 20*time.Millisecond {
+		t.Fatalf("RecommendConfig(50, 2) = %+v, want 100 tokens, one per 20ms", cfg)
+	}
+
+	clock := newFakeClock()
+	b := NewRatataBucket(cfg.Capacity, cfg.RefillRate, WithClock(clock))
+	if !b.AllowN(100) {
+		t.Error("bucket didn't absorb a burst of 2 seconds' worth")
+	}
+	admitted := 0
+	for range 10_000 {
+		clock.Advance(time.Millisecond)
+		if b.Allow() {
+			admitted++
+		}
+	}
+	if admitted < 495 || admitted > 505 {
+		t.Errorf("admitted %d actions over 10s of sustained demand, want about 500", admitted)
+	}
+}
+
+func TestRecommendConfigEdges(t *testing.T) {
+	if got := RecommendConfig(0, 1); got != (Config{}) {
+		t.Errorf("RecommendConfig(0, 1) = %+v, want the zero Config", got)
+	}
+	if got := RecommendConfig(10, 0).Capacity; got != 1 {
+		t.Errorf("RecommendConfig(10, 0) has capacity %d, want 1 for strict pacing", got)
+	}
+}
@@ -4,82 +4,453 @@
 package ratata
 
 import (
+	"math"
 	"sync"
 	"time"
 )
 
 // RatataBucket represents a token bucket with a defined capacity and refill rate.
 // It controls the rate of actions for a user based on the number of available tokens.
+//
+// A RatataBucket must not be copied after first use, as a copy would have its own
+// lock and tokens; always pass it around as a *RatataBucket. go vet reports copies.
 type RatataBucket struct {
+	_ noCopy
+
 	capacity   int           // Maximum number of tokens the bucket can hold.
 	tokens     int           // Current number of tokens in the bucket.
 	refillRate time.Duration // Duration to wait before adding a new token.
+	baseRate   time.Duration // Refill rate before the rate multiplier is applied.
+	multiplier float64       // Factor by which refill is sped up; 1 means the base rate.
 	lastRefill time.Time     // Time of the last token refill.
+	carry      time.Duration // Fraction of a nanosecond past lastRefill, in units of 1/perInterval ns.
+	welcome    int           // Welcome tokens a refund may still restore; 0 once the bucket refills.
+	opts       options       // Optional settings, copied into per-user buckets.
+	stopTicker chan struct{} // Closed to stop the refill ticker; nil when refill is lazy.
+	onRecover  func()        // Called when refill makes an empty bucket usable again.
+	booked     bool          // Whether Reserve has booked tokens ahead, leaving the balance negative.
 	mu         sync.Mutex    // Mutex to protect concurrent access to the bucket's fields.
 }
 
-var (
-	userBuckets = make(map[string]*RatataBucket) // Map to store token buckets for each user.
-	bucketMu    sync.Mutex                       // Mutex to protect concurrent access to the userBuckets map.
-)
+// Limiter is the interface implemented by a single rate-limited resource, such
+// as a RatataBucket. A RatataLimiter keeps one Limiter per user.
+type Limiter interface {
+	Allow() bool       // Allow consumes one token if available.
+	AllowN(n int) bool // AllowN consumes n tokens if all are available.
+	Tokens() int       // Tokens returns the number of tokens currently available.
+}
+
+var _ Limiter = (*RatataBucket)(nil)
+
+// defaultLimiter holds the user buckets of the deprecated RatataBucket.AllowUser.
+var defaultLimiter = NewRatataLimiter(0, time.Second)
 
 // NewRatataBucket creates and returns a new token bucket with a specified capacity and refill rate.
-func NewRatataBucket(capacity int, refillRate time.Duration) *RatataBucket {
+// Optional behavior, such as the clock used for refills, can be configured with opts.
+// A bucket with a capacity of zero denies every action, whatever its options, and
+// never refills, which makes Reconfigure to capacity zero a kill switch.
+func NewRatataBucket(capacity int, refillRate time.Duration, opts ...Option) *RatataBucket {
+	rb := newBucket(capacity, refillRate, newOptions(opts))
+	if rb.opts.tickerInterval > 0 {
+		rb.startTicker(rb.opts.tickerInterval)
+	}
+	return rb
+}
+
+// newBucket creates a full bucket, plus any welcome burst, using already-resolved options.
+func newBucket(capacity int, refillRate time.Duration, o options) *RatataBucket {
+	welcome := 0
+	if capacity > 0 {
+		welcome = o.welcome
+	}
 	return &RatataBucket{
 		capacity:   capacity,
-		tokens:     capacity,
+		tokens:     capacity + welcome,
+		welcome:    welcome,
 		refillRate: refillRate,
-		lastRefill: time.Now(),
+		baseRate:   refillRate,
+		multiplier: 1,
+		lastRefill: o.clock.Now(),
+		opts:       o,
 	}
 }
 
-// refillRatata refills the bucket with tokens based on the elapsed time since the last refill.
-// It ensures that tokens do not exceed the bucket's capacity.
-func (rb *RatataBucket) refillRatata() {
-	rb.mu.Lock()
-	defer rb.mu.Unlock()
+// refillRatata refills the bucket with tokens based on the elapsed time since the
+// last refill. It ensures that tokens do not exceed the bucket's capacity. The
+// caller must hold rb.mu.
+//
+// Only the time needed to earn the added tokens is consumed, so a partial interval
+// carries over to the next refill instead of being discarded. A bucket that reaches
+// capacity cannot bank time, so its remainder is dropped, and so is any time spent
+// while it is full.
+//
+// In ticker mode (see WithTickerRefill) the ticker keeps the bucket refilled, so this
+// does nothing and callers simply read the current tokens.
+//
+// Every caller refills under rb.mu, in the same critical section as the decision
+// that follows, so concurrent callers never credit the same elapsed time twice: the
+// first to take the lock credits it and moves lastRefill forward, and the others then
+// find nothing left to credit, however many read the clock at the same instant.
+func (rb *RatataBucket) refillRatata(now time.Time) {
+	if rb.stopTicker != nil {
+		return
+	}
+	rb.advanceRatata(now)
+}
+
+// advanceRatata credits the tokens earned between the last refill and now.
+// The caller must hold rb.mu.
+func (rb *RatataBucket) advanceRatata(now time.Time) {
+	defer rb.checkRatata()
 
-	now := time.Now()
 	elapsed := now.Sub(rb.lastRefill)
+	if rb.tokens >= rb.capacity {
+		if elapsed > 0 {
+			rb.lastRefill, rb.carry = now, 0 // A full bucket earns nothing, so don't let it bank time.
+		}
+		return
+	}
+	if rb.opts.refillFunc != nil {
+		rb.creditRatata(now, rb.opts.refillFunc(now, rb))
+		return
+	}
+	elapsed = rb.accruedRatata(now)
+	if elapsed < rb.opts.rounding.creditDelay(rb.refillRate) {
+		// Too little time has passed to earn a token. This also covers repeated calls
+		// within the same instant, which then skip the division entirely.
+		return
+	}
 
 	// Calculate how many tokens to add based on the time elapsed and refill rate.
-	newTokens := int(elapsed / rb.refillRate)
+	newTokens := rb.opts.rounding.tokensFor(elapsed, rb.refillRate)
+	wasEmpty := rb.tokens-rb.opts.reserve <= 0
+	rb.welcome = 0 // The bucket is below capacity, so any welcome tokens are spent.
 
-	if newTokens > 0 {
+	if newTokens >= rb.capacity-rb.tokens { // Compared this way so a long idle period can't overflow.
+		rb.tokens = rb.capacity // Ensure tokens do not exceed capacity.
+		// Drop the remainder, but a token credited early still needs its interval.
+		rb.setRefillRatata(now, rb.opts.rounding.lead(elapsed, rb.refillRate))
+	} else {
 		rb.tokens += newTokens
-		if rb.tokens > rb.capacity {
-			rb.tokens = rb.capacity // Ensure tokens do not exceed capacity.
-		}
-		rb.lastRefill = now // Update the last refill time.
+		rb.setRefillRatata(rb.lastRefill, rb.carry+time.Duration(newTokens)*rb.refillRate) // Carry the remainder forward.
+	}
+
+	if wasEmpty && rb.onRecover != nil && rb.tokens-rb.opts.reserve > 0 {
+		rb.onRecover() // The bucket went from throttled to usable.
+	}
+}
+
+// accruedRatata returns the refill time accrued between the last refill and now, in
+// units of 1/perInterval ns, so that with WithTokensPerInterval one token takes
+// exactly refillRate units. It saturates instead of overflowing. The caller must
+// hold rb.mu.
+func (rb *RatataBucket) accruedRatata(now time.Time) time.Duration {
+	elapsed, k := now.Sub(rb.lastRefill), rb.opts.perInterval
+	switch {
+	case k == 1:
+		return elapsed
+	case elapsed > math.MaxInt64/k:
+		return math.MaxInt64
+	case elapsed < math.MinInt64/k:
+		return math.MinInt64
+	}
+	return elapsed*k - rb.carry
+}
+
+// setRefillRatata moves the last refill to d units of 1/perInterval ns after t,
+// keeping the fraction of a nanosecond in rb.carry. The caller must hold rb.mu.
+func (rb *RatataBucket) setRefillRatata(t time.Time, d time.Duration) {
+	k := rb.opts.perInterval
+	whole, frac := d/k, d%k
+	if frac < 0 {
+		whole, frac = whole-1, frac+k
+	}
+	rb.lastRefill, rb.carry = t.Add(whole), frac
+}
+
+// creditRatata adds n tokens from a refill function as of now, up to capacity.
+// The caller must hold rb.mu.
+func (rb *RatataBucket) creditRatata(now time.Time, n int) {
+	rb.lastRefill, rb.carry = now, 0
+	if n <= 0 {
+		return
+	}
+	wasEmpty := rb.tokens-rb.opts.reserve <= 0
+	rb.welcome = 0
+	rb.tokens += min(n, rb.capacity-rb.tokens)
+	if wasEmpty && rb.onRecover != nil && rb.tokens-rb.opts.reserve > 0 {
+		rb.onRecover()
 	}
 }
 
 // Allow checks if a token is available and consumes one if so.
 // Returns true if an action is allowed (token available), false otherwise.
 func (rb *RatataBucket) Allow() bool {
-	rb.refillRatata() // Refill tokens before allowing the action.
+	return rb.AllowAt(rb.opts.clock.Now())
+}
 
+// AllowAt behaves like Allow but evaluates the bucket as of the given time instead
+// of reading the clock. It is meant for replaying recorded traffic; times should be
+// supplied in chronological order, as a time before the last refill adds no tokens.
+func (rb *RatataBucket) AllowAt(now time.Time) bool {
 	rb.mu.Lock()
 	defer rb.mu.Unlock()
 
-	if rb.tokens > 0 {
-		rb.tokens-- // Consume one token.
+	return rb.allowRatata(now)
+}
+
+// AllowN checks if n tokens are available and consumes them all if so. It never
+// consumes a partial amount. Returns true if the action is allowed, false otherwise.
+// In overdraft mode (see WithOverdraft) it always succeeds. With WithPartialConsume,
+// it instead consumes as many of the n tokens as are available and succeeds if it
+// got any; use ConsumeN to learn how many.
+func (rb *RatataBucket) AllowN(n int) bool {
+	return n <= 0 || rb.ConsumeN(n) > 0
+}
+
+// ConsumeN consumes n tokens if all are available and returns the number consumed,
+// which is n or zero. With WithPartialConsume, it consumes as many of the n tokens as
+// are available instead, down to zero, so the caller can charge for exactly what it
+// got. In overdraft mode it always consumes all n.
+func (rb *RatataBucket) ConsumeN(n int) int {
+	if n <= 0 {
+		return 0
+	}
+
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	now := rb.opts.clock.Now()
+	if !rb.opts.partial || rb.overdraftRatata() {
+		if rb.allowNRatata(now, n) {
+			return n
+		}
+		return 0
+	}
+
+	rb.refillRatata(now)
+	got := min(n, max(rb.tokens-rb.opts.reserve, 0))
+	rb.tokens -= got
+	return got
+}
+
+// AllowPriority checks if a token is available and consumes one if so, like Allow,
+// but may also consume the reserve set with WithReserve. It is meant for priority
+// requests that must get through when ordinary traffic has used up its share.
+func (rb *RatataBucket) AllowPriority() bool {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	return rb.takeRatata(rb.opts.clock.Now(), 1, 0)
+}
+
+// allowRatata refills the bucket as of now and consumes one token if available.
+// The caller must hold rb.mu.
+func (rb *RatataBucket) allowRatata(now time.Time) bool {
+	return rb.allowNRatata(now, 1)
+}
+
+// allowNRatata refills the bucket as of now and consumes n tokens if available above
+// the reserve, or unconditionally in overdraft mode. The caller must hold rb.mu.
+func (rb *RatataBucket) allowNRatata(now time.Time, n int) bool {
+	return rb.takeRatata(now, n, rb.opts.reserve)
+}
+
+// takeRatata refills the bucket as of now and consumes n tokens if that leaves
+// at least floor tokens, or unconditionally in overdraft mode. The caller must
+// hold rb.mu.
+func (rb *RatataBucket) takeRatata(now time.Time, n, floor int) bool {
+	rb.refillRatata(now) // Refill tokens before allowing the action.
+
+	if n <= 0 {
+		return true // Nothing to consume.
+	}
+	if rb.overdraftRatata() || rb.tokens-floor >= n {
+		rb.tokens -= n // Consume the tokens.
+		rb.checkRatata()
 		return true
 	}
 	return false
 }
 
+// overdraftRatata reports whether the bucket may be overdrawn: in overdraft mode,
+// unless its capacity is zero, which denies everything. The caller must hold rb.mu.
+func (rb *RatataBucket) overdraftRatata() bool {
+	return rb.opts.overdraft && rb.capacity > 0
+}
+
+// Balance returns the number of tokens in the bucket after refilling. In overdraft
+// mode, or after Reserve has booked tokens ahead, the balance can be negative, and
+// its magnitude is the amount consumed beyond what the bucket could supply; refill
+// pays it back before tokens become available.
+func (rb *RatataBucket) Balance() int {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.refillRatata(rb.opts.clock.Now())
+	return rb.tokens
+}
+
+// retryAfterRatata returns how long after now the next token will be credited, or
+// zero if a token is already available. The caller must hold rb.mu and have
+// refilled the bucket as of now.
+func (rb *RatataBucket) retryAfterRatata(now time.Time) time.Duration {
+	return rb.delayRatata(now, rb.opts.reserve+1)
+}
+
+// Capacity returns the maximum number of tokens the bucket can hold.
+func (rb *RatataBucket) Capacity() int {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	return rb.capacity
+}
+
+// Tokens returns the number of tokens available to Allow after refilling, which
+// excludes any reserve set with WithReserve. It never reports a negative count; use
+// Balance to see the full, possibly overdrawn, balance.
+func (rb *RatataBucket) Tokens() int {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.refillRatata(rb.opts.clock.Now())
+	return max(rb.tokens-rb.opts.reserve, 0)
+}
+
+// Utilization returns the fraction of the bucket's capacity that is used up after
+// refill, from 0 for a full bucket to 1 for an empty one. It counts every token,
+// including any reserve; a bucket in overdraft reports more than 1 and one still
+// holding welcome tokens less than 0. A zero-capacity bucket reports 1.
+func (rb *RatataBucket) Utilization() float64 {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.refillRatata(rb.opts.clock.Now())
+	if rb.capacity <= 0 {
+		return 1
+	}
+	return float64(rb.capacity-rb.tokens) / float64(rb.capacity)
+}
+
+// Reset refills the bucket to its full capacity at once, regardless of how much
+// time has passed, clearing any overdraft. Partial progress towards the next token
+// is dropped.
+func (rb *RatataBucket) Reset() {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.tokens = max(rb.tokens, rb.capacity)
+	rb.lastRefill, rb.carry = rb.opts.clock.Now(), 0
+}
+
+// Peek reports whether a call to Allow would currently succeed, without consuming a token.
+func (rb *RatataBucket) Peek() bool {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.refillRatata(rb.opts.clock.Now())
+	return rb.overdraftRatata() || rb.tokens-rb.opts.reserve > 0
+}
+
+// IsEmpty reports whether the bucket has no tokens left above its reserve after
+// refilling, meaning the next call to Allow would be denied. In overdraft mode the
+// next call always succeeds, so it reports false.
+func (rb *RatataBucket) IsEmpty() bool {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.refillRatata(rb.opts.clock.Now())
+	return !rb.overdraftRatata() && rb.tokens-rb.opts.reserve <= 0
+}
+
+// IsFull reports whether the bucket holds its full capacity of tokens after refilling.
+func (rb *RatataBucket) IsFull() bool {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.refillRatata(rb.opts.clock.Now())
+	return rb.tokens >= rb.capacity
+}
+
+// SetRefillRate changes the duration it takes to add one token. Tokens earned at the
+// old rate are credited first, and progress towards the next token is carried over
+// proportionally, so changing the rate never loses accrued time. A rate multiplier
+// set with SetRateMultiplier keeps applying on top of the new rate.
+func (rb *RatataBucket) SetRefillRate(refillRate time.Duration) {
+	if refillRate <= 0 {
+		return
+	}
+
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.baseRate = refillRate
+	rb.setRateRatata(rb.opts.clock.Now(), rb.scaledRate())
+}
+
+// SetRateMultiplier speeds up or slows down refill, making the effective refill rate
+// the base rate divided by factor. A factor above 1 refills faster, below 1 slower,
+// and 1 restores the base rate. As with SetRefillRate, accrued time is preserved.
+// Non-positive factors are ignored.
+func (rb *RatataBucket) SetRateMultiplier(factor float64) {
+	if factor <= 0 {
+		return
+	}
+
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.multiplier = factor
+	rb.setRateRatata(rb.opts.clock.Now(), rb.scaledRate())
+}
+
+// scaledRate returns the base rate adjusted by the rate multiplier. The caller must hold rb.mu.
+func (rb *RatataBucket) scaledRate() time.Duration {
+	if rb.multiplier == 1 {
+		return rb.baseRate
+	}
+	return max(time.Duration(float64(rb.baseRate)/rb.multiplier), 1)
+}
+
+// setRateRatata switches the bucket to refillRate as of now, crediting tokens earned
+// at the old rate first. The caller must hold rb.mu.
+func (rb *RatataBucket) setRateRatata(now time.Time, refillRate time.Duration) {
+	rb.refillRatata(now)
+
+	// Rescale the partial interval so the same fraction of a token stays earned.
+	if progress := rb.accruedRatata(now); progress != 0 {
+		scaled := time.Duration(float64(progress) / float64(rb.refillRate) * float64(refillRate))
+		rb.setRefillRatata(now, -scaled)
+	}
+	rb.refillRate = refillRate
+}
+
 // AllowUser checks or creates a token bucket for a specific user and then checks if an action is allowed.
 // It returns true if the user is allowed to perform the action (token available), false otherwise.
+//
+// Deprecated: The user buckets live in a single limiter shared by every RatataBucket
+// in the process, and a user's bucket gets the configuration of whichever bucket
+// saw the user first, so buckets with different configurations clobber each
+// other's users. Use a RatataLimiter, which keeps its own users, instead.
 func (rb *RatataBucket) AllowUser(userID string) bool {
-	bucketMu.Lock()
-	defer bucketMu.Unlock()
-	
-	// Initialize a new bucket for the user if it doesn't exist.
-	if userBuckets[userID] == nil {
-		userBuckets[userID] = NewRatataBucket(rb.capacity, rb.refillRate)
-	}
-	userBucket := userBuckets[userID]
+	return rb.userBucket(userID).Allow()
+}
+
+// userBucket returns the bucket for userID in the default limiter, creating it with
+// rb's configuration if it doesn't exist. The lookup and the creation happen under a
+// single acquisition of the limiter's lock, so concurrent first calls for the same
+// user always share one bucket. The lock is released before the caller touches the
+// bucket, which has its own mutex.
+func (rb *RatataBucket) userBucket(userID string) *RatataBucket {
+	rl := defaultLimiter
+	s := rl.shard(userID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	return userBucket.Allow()
+	e, ok := s.users[userID]
+	if !ok {
+		// Initialize a new bucket for the user if it doesn't exist.
+		e = rl.addUserLocked(s, userID, newBucket(rb.capacity, rb.refillRate, rb.opts), rl.opts.clock.Now())
+	}
+	return e.limiter.(*RatataBucket)
 }
@@ -0,0 +1,423 @@
+package ratata
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBucketFullAndEmpty(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(2, time.Second, WithClock(clock))
+
+	check := func(stage string, wantFull, wantEmpty bool) {
+		t.Helper()
+		if got := b.IsFull(); got != wantFull {
+			t.Errorf("%s: IsFull = %v, want %v", stage, got, wantFull)
+		}
+		if got := b.IsEmpty(); got != wantEmpty {
+			t.Errorf("%s: IsEmpty = %v, want %v", stage, got, wantEmpty)
+		}
+	}
+
+	check("fresh", true, false)
+	b.Allow()
+	b.Allow()
+	check("drained", false, true)
+	clock.Advance(time.Second)
+	check("one refill", false, false)
+	clock.Advance(time.Second)
+	check("two refills", true, false)
+}
+
+func TestAllowUserFirstCallsShareOneBucket(t *testing.T) {
+	b := NewRatataBucket(5, time.Hour)
+	const userID = "TestAllowUserFirstCallsShareOneBucket"
+	t.Cleanup(func() { defaultLimiter.Remove(userID) })
+
+	var (
+		wg      sync.WaitGroup
+		allowed atomic.Int32
+		buckets sync.Map
+	)
+	for range 200 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buckets.Store(b.userBucket(userID), true)
+			if b.AllowUser(userID) {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	n := 0
+	buckets.Range(func(any, any) bool { n++; return true })
+	if n != 1 {
+		t.Errorf("concurrent first calls created %d buckets, want 1", n)
+	}
+	if got := allowed.Load(); got != 5 {
+		t.Errorf("%d calls were allowed, want the capacity of 5", got)
+	}
+}
+
+func TestSetRefillRateKeepsAccrual(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(5, time.Second, WithClock(clock))
+	drain(b)
+
+	clock.Advance(500 * time.Millisecond) // Halfway to a token.
+	b.SetRefillRate(2 * time.Second)      // Still halfway, now 1s from it.
+	clock.Advance(time.Second)
+	if !b.Allow() {
+		t.Error("denied after completing the interval: accrual was lost on the rate change")
+	}
+}
+
+func TestOverdraft(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(5, time.Second, WithClock(clock), WithOverdraft())
+
+	if !b.AllowN(8) {
+		t.Fatal("AllowN(8) was denied in overdraft mode")
+	}
+	if got := b.Balance(); got != -3 {
+		t.Errorf("Balance = %d after taking 8 of 5, want -3", got)
+	}
+	if got := b.Tokens(); got != 0 {
+		t.Errorf("Tokens = %d while overdrawn, want 0", got)
+	}
+	if b.IsEmpty() || !b.Peek() {
+		t.Error("IsEmpty or Peek reports a denial while overdrawn, but Allow always succeeds")
+	}
+	clock.Advance(4 * time.Second)
+	if got := b.Balance(); got != 1 {
+		t.Errorf("Balance = %d after 4 refills, want 1", got)
+	}
+	clock.Advance(10 * time.Second)
+	if got := b.Balance(); got != 5 {
+		t.Errorf("Balance = %d after a long refill, want the capacity of 5", got)
+	}
+}
+
+func TestAllowNWithoutOverdraft(t *testing.T) {
+	b := NewRatataBucket(5, time.Second)
+	if b.AllowN(6) {
+		t.Error("AllowN(6) was allowed with 5 tokens")
+	}
+	if !b.AllowN(5) {
+		t.Error("AllowN(5) was denied with 5 tokens")
+	}
+	if b.AllowN(1) {
+		t.Error("AllowN(1) was allowed on an empty bucket")
+	}
+}
+
+func TestRedundantRefillsKeepDecisions(t *testing.T) {
+	clock := newFakeClock()
+	plain := NewRatataBucket(3, 300*time.Millisecond, WithClock(clock))
+	busy := NewRatataBucket(3, 300*time.Millisecond, WithClock(clock))
+
+	for i := range 200 {
+		clock.Advance(70 * time.Millisecond)
+		for range 5 {
+			busy.Peek() // Refills again at the same instant.
+		}
+		if got, want := busy.Allow(), plain.Allow(); got != want {
+			t.Fatalf("call %d: allowed %v with redundant refills, %v without", i, got, want)
+		}
+	}
+}
+
+// BenchmarkAllowSameInstant calls Allow on an empty bucket whose next token isn't
+// due, the tight loop in which refill skips its arithmetic.
+func BenchmarkAllowSameInstant(b *testing.B) {
+	clock := newFakeClock()
+	rb := NewRatataBucket(1, time.Hour, WithClock(clock))
+	rb.Allow()
+	for range b.N {
+		rb.Allow()
+	}
+}
+
+// BenchmarkAllowTokenDue calls Allow with a token due on every call, for comparison
+// with BenchmarkAllowSameInstant.
+func BenchmarkAllowTokenDue(b *testing.B) {
+	clock := newFakeClock()
+	rb := NewRatataBucket(1, time.Nanosecond, WithClock(clock))
+	for range b.N {
+		clock.Advance(time.Nanosecond)
+		rb.Allow()
+	}
+}
+
+func TestReserve(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(5, time.Second, WithClock(clock), WithReserve(2))
+
+	if got := drain(b); got != 3 {
+		t.Fatalf("Allow admitted %d of 5 tokens with a reserve of 2, want 3", got)
+	}
+	if got := b.Tokens(); got != 0 || !b.IsEmpty() {
+		t.Errorf("Tokens = %d, IsEmpty = %v at the reserve; want 0, true", got, b.IsEmpty())
+	}
+	if !b.AllowPriority() || !b.AllowPriority() {
+		t.Fatal("AllowPriority couldn't use the reserve")
+	}
+	if b.AllowPriority() {
+		t.Fatal("AllowPriority was admitted with the reserve used up")
+	}
+
+	clock.Advance(2 * time.Second)
+	if b.Allow() {
+		t.Error("Allow was admitted before the reserve was refilled")
+	}
+	clock.Advance(3 * time.Second)
+	if got := b.Tokens(); got != 3 || !b.IsFull() {
+		t.Errorf("Tokens = %d, IsFull = %v after a full refill; want 3, true", got, b.IsFull())
+	}
+}
+
+func TestPartialConsume(t *testing.T) {
+	b := NewRatataBucket(10, time.Hour, WithPartialConsume())
+	b.AllowN(7)
+	if got := b.ConsumeN(5); got != 3 {
+		t.Errorf("ConsumeN(5) with 3 tokens = %d, want 3", got)
+	}
+	if got := b.Tokens(); got != 0 {
+		t.Errorf("bucket has %d tokens after a partial ConsumeN, want 0", got)
+	}
+	if b.AllowN(1) || b.ConsumeN(3) != 0 {
+		t.Error("an empty bucket admitted an action")
+	}
+
+	if got := NewRatataBucket(5, time.Hour, WithPartialConsume()).Charge(8); !got.OK() || got.Held() != 5 {
+		t.Errorf("Charge(8) from 5 tokens = %v holding %d, want OK holding 5", got.OK(), got.Held())
+	}
+}
+
+func TestConsumeNAllOrNothing(t *testing.T) {
+	b := NewRatataBucket(10, time.Hour)
+	if got := b.ConsumeN(11); got != 0 {
+		t.Errorf("ConsumeN(11) with 10 tokens = %d, want 0", got)
+	}
+	if got := b.Tokens(); got != 10 {
+		t.Errorf("bucket has %d tokens after a denied ConsumeN, want 10", got)
+	}
+	if got := b.ConsumeN(4); got != 4 {
+		t.Errorf("ConsumeN(4) = %d, want 4", got)
+	}
+}
+
+func TestRefillFunc(t *testing.T) {
+	clock := newFakeClock()
+	// Grant two tokens at the start of every minute, a schedule elapsed-time refill
+	// at one token an hour would never follow.
+	next := clock.Now().Add(time.Minute)
+	grant := func(now time.Time, _ *RatataBucket) int {
+		granted := 0
+		for !now.Before(next) {
+			granted += 2
+			next = next.Add(time.Minute)
+		}
+		return granted
+	}
+	b := NewRatataBucket(5, time.Hour, WithClock(clock), WithRefillFunc(grant))
+	b.AllowN(5)
+
+	clock.Advance(59 * time.Second)
+	if b.Allow() {
+		t.Error("admitted an action before the first grant")
+	}
+	clock.Advance(time.Second)
+	if got := drain(b); got != 2 {
+		t.Errorf("admitted %d actions after the first grant, want 2", got)
+	}
+	clock.Advance(10 * time.Minute)
+	if got := b.Tokens(); got != 5 {
+		t.Errorf("Tokens after ten grants = %d, want the capacity of 5", got)
+	}
+}
+
+func TestRefillFuncNotCalledWhenFull(t *testing.T) {
+	calls := 0
+	b := NewRatataBucket(5, time.Second, WithRefillFunc(func(time.Time, *RatataBucket) int {
+		calls++
+		return 1
+	}))
+	b.Tokens()
+	b.Allow()
+	if calls != 0 {
+		t.Errorf("refill func called %d times while the bucket was full, want 0", calls)
+	}
+	b.Tokens()
+	if calls != 1 {
+		t.Errorf("refill func called %d times once a token was taken, want 1", calls)
+	}
+}
+
+func TestZeroCapacityDeniesAll(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts []Option
+	}{
+		{"default", nil},
+		{"overdraft", []Option{WithOverdraft()}},
+		{"partial consume", []Option{WithPartialConsume()}},
+		{"round ceil", []Option{WithRoundingMode(RoundCeil)}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			// A zero refill rate would otherwise divide by zero.
+			b := NewRatataBucket(0, 0, append(tt.opts, WithClock(clock))...)
+			clock.Advance(time.Hour)
+			if b.Allow() || b.AllowN(3) || b.AllowPriority() || b.ConsumeN(2) != 0 || b.Peek() {
+				t.Error("a zero-capacity bucket admitted an action")
+			}
+			if b.Tokens() != 0 || !b.IsEmpty() {
+				t.Errorf("Tokens = %d, want an empty bucket", b.Tokens())
+			}
+			if err := b.WaitN(context.Background(), 1); err != ErrExceedsCapacity {
+				t.Errorf("WaitN = %v, want %v", err, ErrExceedsCapacity)
+			}
+		})
+	}
+}
+
+func TestReconfigureToZeroCapacity(t *testing.T) {
+	b := NewRatataBucket(5, time.Second)
+	if err := b.Reconfigure(Config{Capacity: 0, RefillRate: time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	if b.Allow() {
+		t.Error("Allow after reconfiguring to zero capacity = true, want false")
+	}
+
+	rl := NewRatataLimiter(0, 0)
+	if rl.Allow() || rl.Tokens() != 0 || rl.AllowUser("alice") || rl.PeekUser("bob") {
+		t.Error("a zero-capacity limiter admitted an action")
+	}
+}
+
+func TestWelcomeBurst(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(5, time.Second, WithClock(clock), WithWelcomeBurst(10))
+
+	// admit reports how many of 20 actions are allowed for alice.
+	admit := func() int {
+		n := 0
+		for range 20 {
+			if rl.AllowUser("alice") {
+				n++
+			}
+		}
+		return n
+	}
+	if got := admit(); got != 15 {
+		t.Errorf("a new user was admitted %d actions, want the capacity of 5 plus 10", got)
+	}
+	clock.Advance(time.Hour)
+	if got := admit(); got != 5 {
+		t.Errorf("after a full refill alice was admitted %d actions, want only the capacity of 5", got)
+	}
+}
+
+func TestWelcomeBurstRefund(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(5, time.Second, WithClock(clock), WithWelcomeBurst(3))
+	b.Charge(2).RefundN(2)
+	if got := b.Tokens(); got != 8 {
+		t.Errorf("Tokens after a refund = %d, want the welcome burst kept at 8", got)
+	}
+	clock.Advance(time.Hour)
+	if got := b.Tokens(); got != 8 {
+		t.Errorf("Tokens = %d, want refill to leave the unspent burst alone", got)
+	}
+}
+
+func TestWelcomeBurstRefundAfterRefill(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(10, time.Second, WithClock(clock), WithWelcomeBurst(5))
+	var releases []func()
+	for range 15 {
+		release, ok := b.AcquireToken()
+		if !ok {
+			t.Fatal("AcquireToken denied within the welcome burst")
+		}
+		releases = append(releases, release)
+	}
+	clock.Advance(time.Hour)
+	for _, release := range releases[:5] {
+		release()
+	}
+	if got := b.Tokens(); got != 10 {
+		t.Errorf("Tokens after refill and 5 refunds = %d, want the capacity of 10", got)
+	}
+}
+
+func TestConcurrentRefillCreditsOnce(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opt  Option
+		want int
+	}{
+		{"one per interval", WithTokensPerInterval(1), 10},
+		{"three per interval", WithTokensPerInterval(3), 30},
+		{"round ceil", WithRoundingMode(RoundCeil), 10},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			b := NewRatataBucket(100, time.Second, WithClock(clock), tt.opt)
+			b.AllowN(100)
+			clock.Advance(10 * time.Second)
+
+			// Every caller refills as of the same instant; only the first may credit it.
+			start := make(chan struct{})
+			var wg sync.WaitGroup
+			for range 64 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					b.Tokens()
+					b.Peek()
+					b.AllowN(0)
+				}()
+			}
+			close(start)
+			wg.Wait()
+			if got := b.Tokens(); got != tt.want {
+				t.Errorf("Tokens = %d after 64 concurrent refills, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConcurrentAllowAfterOneAdvance(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(100, time.Second, WithClock(clock))
+	rl.AllowN(100)
+	clock.Advance(10 * time.Second)
+
+	start := make(chan struct{})
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for range 64 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if rl.Allow() {
+				allowed.Add(1)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	if got := allowed.Load(); got != 10 {
+		t.Errorf("64 concurrent callers were admitted %d times after 10s, want 10", got)
+	}
+}
@@ -0,0 +1,20 @@
+module github.com/vsheshjain/ratata/ratatagrpc
+
+go 1.23.1
+
+require (
+	github.com/vsheshjain/ratata v0.0.0-20261014151640-b5f148d2efa8
+	google.golang.org/grpc v1.71.1
+)
+
+require (
+	golang.org/x/net v0.34.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f // indirect
+	google.golang.org/protobuf v1.36.5 // indirect
+)
+
+// Build against the core in this repository. Replace directives only apply here,
+// so modules that require this one get the version required above.
+replace github.com/vsheshjain/ratata => ../
@@ -0,0 +1,84 @@
+// Package ratatagrpc rate limits gRPC servers with a ratata.RatataLimiter, the
+// counterpart of ratatahttp, so both stacks can share the same limiters.
+package ratatagrpc
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/vsheshjain/ratata"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// KeyFunc extracts the rate-limiting key, such as a user ID, from the context of an
+// incoming call to the method fullMethod, such as "/pkg.Service/Method".
+type KeyFunc func(ctx context.Context, fullMethod string) string
+
+// Metadata keys set in the trailers of rejected calls.
+const (
+	// RetryAfterKey holds the number of seconds until the call's key could next be
+	// allowed, rounded up and at least 1, like the Retry-After header.
+	RetryAfterKey = "retry-after"
+	// PushbackKey holds the same delay in milliseconds, or -1 if the key will never
+	// be allowed, which gRPC clients with a retry policy honor as server pushback.
+	PushbackKey = "grpc-retry-pushback-ms"
+)
+
+// UnaryServerInterceptor returns an interceptor that limits unary calls per key
+// using limiter, one token per call. A denied call fails with
+// codes.ResourceExhausted before reaching the handler, with the retry delay in its
+// trailers under RetryAfterKey and PushbackKey.
+func UnaryServerInterceptor(limiter *ratata.RatataLimiter, keyFunc KeyFunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		res := limiter.AllowUserResult(keyFunc(ctx, info.FullMethod))
+		if !res.Allowed {
+			grpc.SetTrailer(ctx, retryTrailer(res.RetryAfter))
+			return nil, rejected(res.RetryAfter)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns an interceptor that limits streaming calls per key
+// using limiter, one token when the stream opens; messages on an admitted stream
+// are not limited. A denied stream fails as described for UnaryServerInterceptor.
+func StreamServerInterceptor(limiter *ratata.RatataLimiter, keyFunc KeyFunc) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		res := limiter.AllowUserResult(keyFunc(ss.Context(), info.FullMethod))
+		if !res.Allowed {
+			ss.SetTrailer(retryTrailer(res.RetryAfter))
+			return rejected(res.RetryAfter)
+		}
+		return handler(srv, ss)
+	}
+}
+
+// rejected returns the error of a call denied for retryAfter.
+func rejected(retryAfter time.Duration) error {
+	if retryAfter >= ratata.Never {
+		return status.Error(codes.ResourceExhausted, "ratatagrpc: rate limit exceeded")
+	}
+	return status.Errorf(codes.ResourceExhausted, "ratatagrpc: rate limit exceeded, retry after %ds", retrySeconds(retryAfter))
+}
+
+// retryTrailer returns the trailers of a call denied for retryAfter.
+func retryTrailer(retryAfter time.Duration) metadata.MD {
+	if retryAfter >= ratata.Never {
+		return metadata.Pairs(PushbackKey, "-1")
+	}
+	millis := (retryAfter + time.Millisecond - 1) / time.Millisecond
+	return metadata.Pairs(
+		RetryAfterKey, strconv.FormatInt(retrySeconds(retryAfter), 10),
+		PushbackKey, strconv.FormatInt(int64(millis), 10),
+	)
+}
+
+// retrySeconds returns retryAfter in whole seconds, rounded up and at least 1, so a
+// client that waits as long as told is never turned away early.
+func retrySeconds(retryAfter time.Duration) int64 {
+	return int64((max(retryAfter, time.Second) + time.Second - 1) / time.Second)
+}
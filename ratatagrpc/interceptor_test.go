@@ -0,0 +1,91 @@
+package ratatagrpc
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/vsheshjain/ratata"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dial serves the health service over an in-memory connection, limiting unary calls
+// by their api-key metadata and streams by peer, and returns a client for it.
+func dial(t *testing.T, rl *ratata.RatataLimiter) healthpb.HealthClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(rl, KeyByMetadata("api-key"))),
+		grpc.StreamInterceptor(StreamServerInterceptor(rl, KeyByPeer())),
+	)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	rl := ratata.NewRatataLimiter(1, time.Hour)
+	client := dial(t, rl)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "api-key", "alice")
+
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("first call = %v, want success", err)
+	}
+	var trailer metadata.MD
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Trailer(&trailer))
+	if code := status.Code(err); code != codes.ResourceExhausted {
+		t.Fatalf("second call = %v, want %v", err, codes.ResourceExhausted)
+	}
+	if got := trailer.Get(RetryAfterKey); len(got) != 1 || got[0] != "3600" {
+		t.Errorf("%s = %q, want 3600 seconds", RetryAfterKey, got)
+	}
+	if got := trailer.Get(PushbackKey); len(got) != 1 {
+		t.Errorf("%s = %q, want one value", PushbackKey, got)
+	} else if ms, err := strconv.Atoi(got[0]); err != nil || ms < 3_599_000 || ms > 3_600_000 {
+		t.Errorf("%s = %q, want about an hour in milliseconds", PushbackKey, got[0])
+	}
+	if _, ok := rl.UserMeta("alice"); !ok {
+		t.Error("unary calls weren't keyed by their api-key metadata")
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	rl := ratata.NewRatataLimiter(1, time.Hour)
+	client := dial(t, rl)
+
+	stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("first stream = %v, want success", err)
+	}
+	stream, err = client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("second stream = %v, want %v", err, codes.ResourceExhausted)
+	}
+	if got := stream.Trailer().Get(PushbackKey); len(got) != 1 || got[0] == "" {
+		t.Errorf("%s = %q, want a retry hint", PushbackKey, got)
+	}
+}
@@ -0,0 +1,38 @@
+package ratatagrpc
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// KeyByPeer returns a KeyFunc that keys calls by the IP address of the client
+// connection. Behind a proxy that is the proxy's address; use KeyByMetadata with the
+// key the proxy sets instead, and only if clients can't set it themselves.
+func KeyByPeer() KeyFunc {
+	return func(ctx context.Context, _ string) string {
+		p, ok := peer.FromContext(ctx)
+		if !ok || p.Addr == nil {
+			return ""
+		}
+		addr := p.Addr.String()
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return addr // No port, such as from a unix socket or bufconn.
+		}
+		return host
+	}
+}
+
+// KeyByMetadata returns a KeyFunc that keys calls by the first value of the incoming
+// metadata key name, such as an API key. Calls without it share the empty key.
+func KeyByMetadata(name string) KeyFunc {
+	return func(ctx context.Context, _ string) string {
+		if v := metadata.ValueFromIncomingContext(ctx, name); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+}
@@ -0,0 +1,40 @@
+package ratatahttp
+
+import (
+	"net"
+	"net/http"
+)
+
+// KeyByIP returns a KeyFunc that keys requests by the IP address of the client
+// connection, from the request's RemoteAddr. Behind a reverse proxy that is the
+// proxy's address; use KeyByHeader with the header the proxy sets, such as
+// X-Real-IP, instead, and only if clients can't set that header themselves.
+func KeyByIP() KeyFunc {
+	return func(r *http.Request) string {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr // No port, such as from a unix socket or a test.
+		}
+		return host
+	}
+}
+
+// KeyByHeader returns a KeyFunc that keys requests by the value of the header name,
+// such as an API key. Requests without the header share the empty key.
+func KeyByHeader(name string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// KeyByCookie returns a KeyFunc that keys requests by the value of the cookie name,
+// such as a session ID. Requests without the cookie share the empty key.
+func KeyByCookie(name string) KeyFunc {
+	return func(r *http.Request) string {
+		c, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return c.Value
+	}
+}
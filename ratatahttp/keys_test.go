@@ -0,0 +1,31 @@
+package ratatahttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKeyFuncs(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "192.0.2.1:5555"
+	r.Header.Set("X-Api-Key", "secret")
+	r.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+
+	tests := []struct {
+		name string
+		key  KeyFunc
+		want string
+	}{
+		{"ip", KeyByIP(), "192.0.2.1"},
+		{"header", KeyByHeader("X-Api-Key"), "secret"},
+		{"missing header", KeyByHeader("X-Other"), ""},
+		{"cookie", KeyByCookie("session"), "abc"},
+		{"missing cookie", KeyByCookie("other"), ""},
+	}
+	for _, tt := range tests {
+		if got := tt.key(r); got != tt.want {
+			t.Errorf("%s key = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package ratatahttp
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vsheshjain/ratata"
+)
+
+// KeyFunc extracts the rate-limiting key, such as a user ID, from a request.
+type KeyFunc func(r *http.Request) string
+
+// RateInfo is the rate-limit state of the current request's key, as seen by the
+// middleware when it admitted the request.
+type RateInfo struct {
+	Remaining  int           // Tokens left for the key after this request.
+	RetryAfter time.Duration // Time until the key's next token, zero if one is available.
+	Meta       any           // Metadata attached to the key with SetUserMeta, or nil.
+}
+
+// contextKey is the type of context keys defined by this package.
+type contextKey struct {
+	name string
+}
+
+// RateInfoKey is the context key under which Middleware stores the RateInfo of a
+// request, for downstream handlers of admitted requests and for the function set
+// with WithRejectBody. Use FromContext to read it.
+var RateInfoKey = &contextKey{"rate-info"}
+
+// FromContext returns the RateInfo stored in ctx by Middleware, if any.
+func FromContext(ctx context.Context) (RateInfo, bool) {
+	info, ok := ctx.Value(RateInfoKey).(RateInfo)
+	return info, ok
+}
+
+// Middleware returns a middleware that limits requests per key using limiter. Denied
+// requests get a 429 Too Many Requests response unless configured otherwise with
+// WithRejectStatus and WithRejectBody. Requests carry their RateInfo in the request
+// context so downstream handlers, and the function set with WithRejectBody, can read
+// it with FromContext without querying the limiter again. Each request costs one
+// token unless configured otherwise with WithCost.
+//
+// Every response carries the X-RateLimit-Limit, X-RateLimit-Remaining and
+// X-RateLimit-Reset headers, the last being the number of seconds until the key's
+// bucket is full again, unless disabled with WithHeaders. Denied responses also
+// carry Retry-After, in seconds and at least 1. Durations are rounded up to whole
+// seconds, so a client that waits as long as told is never turned away early.
+//
+// A request that costs more tokens than the key's bucket holds can never be
+// admitted, so it gets a 413 Request Entity Too Large response without Retry-After,
+// rather than a 429 telling the client to retry.
+func Middleware(limiter *ratata.RatataLimiter, keyFunc KeyFunc, opts ...Option) func(http.Handler) http.Handler {
+	cfg := newConfig(opts)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cost := cfg.requestCost(r)
+			res := limiter.AllowUserResultN(keyFunc(r), cost)
+			info := RateInfo{Remaining: res.Remaining, RetryAfter: res.RetryAfter, Meta: res.Meta}
+			r = r.WithContext(context.WithValue(r.Context(), RateInfoKey, info))
+			if cfg.headers {
+				setRateHeaders(w.Header(), res)
+			}
+			if !res.Allowed && res.Reason == ratata.ReasonNoTokens && res.Limit > 0 && cost > res.Limit {
+				// Waiting won't help a request that costs more than the whole bucket.
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			if !res.Allowed {
+				w.Header().Set("Retry-After", seconds(max(res.RetryAfter, time.Second)))
+				cfg.reject(w, r, res.RetryAfter)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// setRateHeaders sets the X-RateLimit headers describing res.
+func setRateHeaders(h http.Header, res ratata.Result) {
+	h.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("X-RateLimit-Reset", seconds(res.ResetAfter))
+}
+
+// seconds formats d as a whole number of seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
+}
@@ -0,0 +1,171 @@
+package ratatahttp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vsheshjain/ratata"
+)
+
+// serve sends r to h and returns the recorded response.
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// byKey is a KeyFunc that limits every request under the same key.
+func byKey(*http.Request) string { return "k" }
+
+// ok is a handler that accepts every request.
+var ok = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+
+func TestMiddlewareRateInfoInContext(t *testing.T) {
+	rl := ratata.NewRatataLimiter(2, time.Hour)
+	var infos []RateInfo
+	h := Middleware(rl, byKey)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, ok := FromContext(r.Context())
+		if !ok {
+			t.Error("no RateInfo in the request context")
+		}
+		infos = append(infos, info)
+	}))
+
+	for range 3 {
+		serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	if len(infos) != 2 {
+		t.Fatalf("handler ran %d times, want 2", len(infos))
+	}
+	if infos[0].Remaining != 1 || infos[1].Remaining != 0 {
+		t.Errorf("Remaining = %d, %d; want 1, 0", infos[0].Remaining, infos[1].Remaining)
+	}
+	if infos[1].RetryAfter < 59*time.Minute {
+		t.Errorf("RetryAfter = %v for an empty bucket refilling hourly, want about an hour", infos[1].RetryAfter)
+	}
+}
+
+func TestMiddlewareCustomReject(t *testing.T) {
+	rl := ratata.NewRatataLimiter(1, time.Minute)
+	var retryAfter time.Duration
+	h := Middleware(rl, byKey, WithRejectStatus(http.StatusServiceUnavailable),
+		WithRejectBody(func(w http.ResponseWriter, r *http.Request, d time.Duration) {
+			retryAfter = d
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"retry_after":%d}`, int(d.Seconds()))
+		}))(ok)
+
+	serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	w := serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want the one set by the body writer", got)
+	}
+	if retryAfter < 59*time.Second || retryAfter > time.Minute {
+		t.Errorf("body writer got a retry-after of %v, want about a minute", retryAfter)
+	}
+}
+
+func TestMiddlewareDefaultReject(t *testing.T) {
+	rl := ratata.NewRatataLimiter(0, time.Minute)
+	w := serve(Middleware(rl, byKey)(ok), httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("status %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Body.String(); got != http.StatusText(http.StatusTooManyRequests)+"\n" {
+		t.Errorf("body %q, want the status text", got)
+	}
+}
+
+func TestMiddlewareHeaders(t *testing.T) {
+	rl := ratata.NewRatataLimiter(2, 1500*time.Millisecond)
+	h := Middleware(rl, KeyByIP())(ok)
+	request := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "192.0.2.1:5555"
+		return serve(h, r)
+	}
+
+	// Reset is when the bucket is full again, rounded up to whole seconds.
+	w := request()
+	for key, want := range map[string]string{"X-RateLimit-Limit": "2", "X-RateLimit-Remaining": "1", "X-RateLimit-Reset": "2"} {
+		if got := w.Header().Get(key); got != want {
+			t.Errorf("first response %s = %q, want %q", key, got, want)
+		}
+	}
+	request()
+	w = request()
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third response = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2, 1.5s rounded up", got)
+	}
+	if got := w.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("X-RateLimit-Remaining = %q, want 0", got)
+	}
+	if _, ok := rl.UserMeta("192.0.2.1"); !ok {
+		t.Error("requests weren't keyed by the client's IP without its port")
+	}
+}
+
+func TestContentLengthCost(t *testing.T) {
+	rl := ratata.NewRatataLimiter(100, time.Hour)
+	h := Middleware(rl, byKey, WithCost(ContentLengthCost(10, 50)))(ok)
+	post := func(body string, unknownLength bool) int {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		if unknownLength {
+			r.ContentLength = -1
+		}
+		return serve(h, r).Code
+	}
+
+	tests := []struct {
+		name          string
+		body          string
+		unknownLength bool
+		want          int
+	}{
+		{"95 tokens", strings.Repeat("x", 950), false, http.StatusOK},
+		{"10 tokens with 5 left", strings.Repeat("x", 100), false, http.StatusTooManyRequests},
+		{"1 byte rounds up", "x", false, http.StatusOK},
+		{"empty body costs 1", "", false, http.StatusOK},
+		{"unknown length costs 50", "", true, http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		if got := post(tt.body, tt.unknownLength); got != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, got, tt.want)
+		}
+	}
+	if got := rl.AllowUserResult("k").Remaining; got != 2 {
+		t.Errorf("Remaining = %d, want 2", got)
+	}
+}
+
+func TestMiddlewareRejectsCostOverCapacity(t *testing.T) {
+	rl := ratata.NewRatataLimiter(100, time.Hour)
+	h := Middleware(rl, byKey, WithCost(ContentLengthCost(1, 1)))(ok)
+
+	w := serve(h, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", 101))))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+	if got := w.Header().Get("Retry-After"); got != "" {
+		t.Errorf("Retry-After = %q, want none", got)
+	}
+	if got := rl.AllowUserResult("k").Remaining; got != 99 {
+		t.Errorf("Remaining = %d, want 99: the oversized request consumed tokens", got)
+	}
+
+	w = serve(h, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", 100))))
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("request within capacity: status %d, Retry-After %q; want 429 with a retry hint",
+			w.Code, w.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,131 @@
+package ratatahttp
+
+import (
+	"net/http"
+	"time"
+)
+
+// Option configures Middleware.
+type Option func(*config)
+
+// RejectFunc writes the response for a request that was rate limited. retryAfter is
+// how long the client should wait before trying again.
+type RejectFunc func(w http.ResponseWriter, r *http.Request, retryAfter time.Duration)
+
+// CostFunc returns the number of tokens a request costs.
+type CostFunc func(r *http.Request) int
+
+// config holds the settings of a Middleware.
+type config struct {
+	rejectStatus int        // Status code of rejected requests.
+	rejectBody   RejectFunc // Writes the body of rejected requests, if set.
+	cost         CostFunc   // Computes the cost of each request, if set.
+	headers      bool       // Whether to send the X-RateLimit headers.
+}
+
+// newConfig applies opts on top of the defaults.
+func newConfig(opts []Option) config {
+	c := config{rejectStatus: http.StatusTooManyRequests, headers: true}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// WithRejectStatus sets the status code sent for rate-limited requests, such as 503
+// for gateways that expect it. The default is 429 Too Many Requests.
+func WithRejectStatus(code int) Option {
+	return func(c *config) {
+		c.rejectStatus = code
+	}
+}
+
+// WithRejectBody sets a function that writes the response for rate-limited
+// requests, for JSON error envelopes or messages that include the retry delay. The
+// function may set headers before writing; the response gets the status set with
+// WithRejectStatus unless the function calls WriteHeader itself. By default the
+// status text is written as plain text.
+func WithRejectBody(fn RejectFunc) Option {
+	return func(c *config) {
+		c.rejectBody = fn
+	}
+}
+
+// WithHeaders sets whether responses carry the X-RateLimit-Limit,
+// X-RateLimit-Remaining and X-RateLimit-Reset headers, which they do by default.
+// Denied responses carry Retry-After either way.
+func WithHeaders(enabled bool) Option {
+	return func(c *config) {
+		c.headers = enabled
+	}
+}
+
+// WithCost makes each request cost the number of tokens returned by fn instead of
+// one, so that expensive requests, such as large uploads, consume more of the
+// budget. A request is admitted only if all of its tokens are available. See
+// ContentLengthCost for limiting by payload size.
+func WithCost(fn CostFunc) Option {
+	return func(c *config) {
+		c.cost = fn
+	}
+}
+
+// ContentLengthCost returns a CostFunc that charges one token per bytesPerToken
+// bytes of the request body, rounded up, for bandwidth or payload limiting. Every
+// request costs at least one token, and requests without a known Content-Length
+// cost defaultCost. A non-positive bytesPerToken charges one token per byte.
+func ContentLengthCost(bytesPerToken int64, defaultCost int) CostFunc {
+	bytesPerToken = max(bytesPerToken, 1)
+	return func(r *http.Request) int {
+		if r.ContentLength < 0 {
+			return defaultCost
+		}
+		return int(max((r.ContentLength+bytesPerToken-1)/bytesPerToken, 1))
+	}
+}
+
+// requestCost returns the number of tokens r costs.
+func (c config) requestCost(r *http.Request) int {
+	if c.cost == nil {
+		return 1
+	}
+	return c.cost(r)
+}
+
+// reject writes the response for a rate-limited request.
+func (c config) reject(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	if c.rejectBody == nil {
+		http.Error(w, http.StatusText(c.rejectStatus), c.rejectStatus)
+		return
+	}
+
+	sw := &statusWriter{ResponseWriter: w, status: c.rejectStatus}
+	c.rejectBody(sw, r, retryAfter)
+	if !sw.wroteHeader {
+		sw.WriteHeader(c.rejectStatus) // The function wrote nothing at all.
+	}
+}
+
+// statusWriter sends a default status code unless the handler sends its own.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int  // Status to send if the handler doesn't choose one.
+	wroteHeader bool // Whether the header has been written.
+}
+
+// WriteHeader sends code as the response status.
+func (sw *statusWriter) WriteHeader(code int) {
+	if sw.wroteHeader {
+		return
+	}
+	sw.wroteHeader = true
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+// Write sends the default status first if no status has been sent yet.
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if !sw.wroteHeader {
+		sw.WriteHeader(sw.status)
+	}
+	return sw.ResponseWriter.Write(b)
+}
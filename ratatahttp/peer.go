@@ -0,0 +1,55 @@
+package ratatahttp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/vsheshjain/ratata"
+)
+
+// SnapshotHandler returns a handler that serves the limiter's Snapshot as JSON, so
+// that a new instance can warm up from it with WarmFromPeer. Mount it where only
+// peers can reach it, as the snapshot lists every tracked user.
+func SnapshotHandler(limiter *ratata.RatataLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(limiter.Snapshot())
+	}
+}
+
+// WarmFromPeer fetches the snapshot served by a peer's SnapshotHandler at url and
+// restores it into limiter, so an instance starting up doesn't give every user a
+// fresh burst. It is best-effort state sharing for deployments without a Store:
+// users the peer saw after serving the snapshot are not carried over. A snapshot in
+// a format version this package doesn't know is rejected with
+// ratata.ErrUnsupportedSnapshot, leaving limiter untouched, as is a response that
+// isn't a snapshot. A nil client means http.DefaultClient.
+func WarmFromPeer(ctx context.Context, client *http.Client, url string, limiter *ratata.RatataLimiter) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ratatahttp: fetching snapshot from %s: %s", url, resp.Status)
+	}
+	var snap ratata.Snapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snap); err != nil {
+		return fmt.Errorf("ratatahttp: decoding snapshot from %s: %w", url, err)
+	}
+	if !snap.Supported() {
+		return fmt.Errorf("ratatahttp: snapshot from %s has version %d: %w", url, snap.Version, ratata.ErrUnsupportedSnapshot)
+	}
+	limiter.Restore(snap)
+	return nil
+}
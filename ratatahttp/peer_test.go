@@ -0,0 +1,54 @@
+package ratatahttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vsheshjain/ratata"
+)
+
+func TestWarmFromPeer(t *testing.T) {
+	peer := ratata.NewRatataLimiter(5, time.Hour)
+	peer.AllowUserResultN("alice", 5)
+	srv := httptest.NewServer(SnapshotHandler(peer))
+	defer srv.Close()
+
+	fresh := ratata.NewRatataLimiter(5, time.Hour)
+	if err := WarmFromPeer(context.Background(), nil, srv.URL, fresh); err != nil {
+		t.Fatal(err)
+	}
+	if fresh.AllowUser("alice") {
+		t.Error("alice was drained on the peer but not after warming")
+	}
+	if !fresh.AllowUser("bob") {
+		t.Error("bob is unknown to the peer and should start full")
+	}
+}
+
+func TestWarmFromPeerUnsupportedVersion(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"version":99,"users":[{"user_id":"alice","tokens":0}]}`))
+	}))
+	defer srv.Close()
+
+	fresh := ratata.NewRatataLimiter(5, time.Hour)
+	if err := WarmFromPeer(context.Background(), nil, srv.URL, fresh); !errors.Is(err, ratata.ErrUnsupportedSnapshot) {
+		t.Errorf("WarmFromPeer = %v, want %v", err, ratata.ErrUnsupportedSnapshot)
+	}
+	if got := fresh.CountTotalUsers(); got != 0 {
+		t.Errorf("an unsupported snapshot restored %d users, want none", got)
+	}
+}
+
+func TestWarmFromPeerHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	if err := WarmFromPeer(context.Background(), nil, srv.URL, ratata.NewRatataLimiter(5, time.Hour)); err == nil {
+		t.Error("WarmFromPeer succeeded against a 404")
+	}
+}
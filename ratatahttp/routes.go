@@ -0,0 +1,84 @@
+package ratatahttp
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/vsheshjain/ratata"
+)
+
+// Routes is a declarative table of per-route rate limits, built with NewRoutes and
+// Route and applied to a handler with Handler. Each route gets its own
+// RatataLimiter, so requests to different routes are limited independently, and
+// requests are keyed within each route by the table's KeyFunc.
+//
+// Routes are matched with the pattern syntax and precedence of http.ServeMux, so
+// "POST /upload" and "/api/{id}/" mean what they do there. A Routes must be fully
+// built before Handler is called and must not be changed afterwards.
+type Routes struct {
+	keyFunc  KeyFunc                          // Extracts the key of each request.
+	opts     []Option                         // Options of each route's Middleware.
+	mux      *http.ServeMux                   // Matches requests to patterns; its handlers are never called.
+	limiters map[string]*ratata.RatataLimiter // Limiter of each route, by pattern.
+	fallback *ratata.RatataLimiter            // Limiter of unmatched requests, if set.
+}
+
+// NewRoutes returns an empty table whose routes key requests with keyFunc and
+// reject them as configured by opts, as Middleware does.
+func NewRoutes(keyFunc KeyFunc, opts ...Option) *Routes {
+	return &Routes{
+		keyFunc:  keyFunc,
+		opts:     opts,
+		mux:      http.NewServeMux(),
+		limiters: make(map[string]*ratata.RatataLimiter),
+	}
+}
+
+// Route limits requests matching pattern to capacity tokens per key, refilled one
+// per refillRate, with the bucket options given, and returns rt for chaining. Like
+// http.ServeMux.Handle, it panics if pattern is invalid or already in the table.
+func (rt *Routes) Route(pattern string, capacity int, refillRate time.Duration, opts ...ratata.Option) *Routes {
+	rt.mux.Handle(pattern, http.NotFoundHandler())
+	rt.limiters[pattern] = ratata.NewRatataLimiter(capacity, refillRate, opts...)
+	return rt
+}
+
+// Default limits requests that match no route as Route does, and returns rt for
+// chaining. Without a default, unmatched requests pass through unlimited.
+func (rt *Routes) Default(capacity int, refillRate time.Duration, opts ...ratata.Option) *Routes {
+	rt.fallback = ratata.NewRatataLimiter(capacity, refillRate, opts...)
+	return rt
+}
+
+// Limiter returns the limiter of the route registered with pattern, or of unmatched
+// requests for the empty pattern, for inspecting stats or changing limits at run
+// time. It returns nil if there is no such route.
+func (rt *Routes) Limiter(pattern string) *ratata.RatataLimiter {
+	if pattern == "" {
+		return rt.fallback
+	}
+	return rt.limiters[pattern]
+}
+
+// Handler returns a handler that limits each request to next with the limiter of
+// the route it matches, applied with Middleware.
+func (rt *Routes) Handler(next http.Handler) http.Handler {
+	handlers := make(map[string]http.Handler, len(rt.limiters))
+	for pattern, limiter := range rt.limiters {
+		handlers[pattern] = Middleware(limiter, rt.keyFunc, rt.opts...)(next)
+	}
+	unmatched := next
+	if rt.fallback != nil {
+		unmatched = Middleware(rt.fallback, rt.keyFunc, rt.opts...)(next)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := rt.mux.Handler(r); pattern != "" {
+			if h, ok := handlers[pattern]; ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+		unmatched.ServeHTTP(w, r)
+	})
+}
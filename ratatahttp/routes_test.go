@@ -0,0 +1,47 @@
+package ratatahttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// code returns the status h responds to a request for method and path with.
+func code(h http.Handler, method, path string) int {
+	return serve(h, httptest.NewRequest(method, path, nil)).Code
+}
+
+func TestRoutesLimitIndependently(t *testing.T) {
+	routes := NewRoutes(byKey).Route("/a/", 1, time.Hour).Route("POST /b", 2, time.Hour)
+	h := routes.Handler(ok)
+
+	if code(h, "GET", "/a/x") != http.StatusOK || code(h, "GET", "/a/y") != http.StatusTooManyRequests {
+		t.Error("/a/ didn't admit exactly one request")
+	}
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if got := code(h, "POST", "/b"); got != want {
+			t.Errorf("POST /b request %d = %d, want %d", i, got, want)
+		}
+	}
+	if got := routes.Limiter("/a/").Stats().Denied; got != 1 {
+		t.Errorf("/a/ limiter denied %d requests, want 1", got)
+	}
+
+	for range 5 {
+		if code(h, "GET", "/b") != http.StatusOK || code(h, "GET", "/c") != http.StatusOK {
+			t.Fatal("an unmatched request was limited")
+		}
+	}
+}
+
+func TestRoutesDefault(t *testing.T) {
+	h := NewRoutes(byKey).Route("/a", 1, time.Hour).Default(1, time.Hour).Handler(ok)
+
+	if code(h, "GET", "/c") != http.StatusOK || code(h, "GET", "/d") != http.StatusTooManyRequests {
+		t.Error("unmatched requests didn't share the default limit")
+	}
+	if code(h, "GET", "/a") != http.StatusOK {
+		t.Error("/a was charged for the default limit")
+	}
+}
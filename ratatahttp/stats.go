@@ -0,0 +1,35 @@
+// Package ratatahttp provides net/http helpers for exposing and enforcing
+// ratata rate limits.
+package ratatahttp
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/vsheshjain/ratata"
+)
+
+// statsResponse is the JSON document served by StatsHandler.
+type statsResponse struct {
+	Users          int     `json:"users"`
+	Allowed        uint64  `json:"allowed"`
+	Denied         uint64  `json:"denied"`
+	RejectedTokens uint64  `json:"rejected_tokens"`
+	UptimeSeconds  float64 `json:"uptime_seconds"`
+}
+
+// StatsHandler returns a handler that serves the limiter's aggregate stats as JSON,
+// ready to be mounted on an admin or health-check mux.
+func StatsHandler(limiter *ratata.RatataLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats := limiter.Stats()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(statsResponse{
+			Users:          stats.Users,
+			Allowed:        stats.Allowed,
+			Denied:         stats.Denied,
+			RejectedTokens: stats.RejectedTokens,
+			UptimeSeconds:  stats.Uptime.Seconds(),
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package ratatahttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vsheshjain/ratata"
+)
+
+func TestStatsHandler(t *testing.T) {
+	rl := ratata.NewRatataLimiter(2, time.Hour)
+	rl.AllowUser("alice")
+	rl.AllowUser("alice")
+	rl.AllowUser("alice")
+	rl.AllowUser("bob")
+
+	w := serve(StatsHandler(rl), httptest.NewRequest(http.MethodGet, "/stats", nil))
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	var got statsResponse
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Users != 2 || got.Allowed != 3 || got.Denied != 1 || got.RejectedTokens != 1 {
+		t.Errorf("stats = %+v, want 2 users, 3 allowed, 1 denied and 1 rejected token", got)
+	}
+}
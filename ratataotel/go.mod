@@ -0,0 +1,13 @@
+module github.com/vsheshjain/ratata/ratataotel
+
+go 1.23.1
+
+require (
+	github.com/vsheshjain/ratata v0.0.0-20261014151640-b5f148d2efa8
+	go.opentelemetry.io/otel v1.35.0
+	go.opentelemetry.io/otel/trace v1.35.0
+)
+
+// Build against the core in this repository. Replace directives only apply here,
+// so modules that require this one get the version required above.
+replace github.com/vsheshjain/ratata => ../
@@ -0,0 +1,71 @@
+// Package ratataotel traces ratata's blocking waits with OpenTelemetry.
+package ratataotel
+
+import (
+	"context"
+	"errors"
+
+	"github.com/vsheshjain/ratata"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attribute keys set on wait spans.
+const (
+	TokensKey  = attribute.Key("ratata.tokens")       // Number of tokens waited for.
+	WaitedKey  = attribute.Key("ratata.wait_seconds") // Time the caller blocked, in seconds.
+	OutcomeKey = attribute.Key("ratata.outcome")      // How the wait ended; see Tracer.
+)
+
+// Tracer returns a ratata.Tracer, for use with ratata.WithTracer, that records each
+// blocking wait as a span of tracer named after the waiting method, such as
+// "ratata.Wait", with the caller's span as its parent. Each span records the number
+// of tokens and how long the caller blocked, and an outcome of "acquired",
+// "denied", "timeout", "canceled" or "error"; a wait that ends in an error also
+// records the error and sets the span's status to Error.
+func Tracer(tracer trace.Tracer) ratata.Tracer {
+	return otelTracer{tracer: tracer}
+}
+
+// otelTracer adapts an OpenTelemetry tracer to ratata.Tracer.
+type otelTracer struct {
+	tracer trace.Tracer
+}
+
+// StartWait starts a span for a wait for n tokens.
+func (t otelTracer) StartWait(ctx context.Context, op string, n int) ratata.WaitSpan {
+	_, span := t.tracer.Start(ctx, "ratata."+op, trace.WithAttributes(TokensKey.Int(n)))
+	return waitSpan{span: span}
+}
+
+// waitSpan adapts an OpenTelemetry span to ratata.WaitSpan.
+type waitSpan struct {
+	span trace.Span
+}
+
+// End records the outcome of the wait and ends the span.
+func (s waitSpan) End(o ratata.WaitOutcome) {
+	s.span.SetAttributes(WaitedKey.Float64(o.Waited.Seconds()), OutcomeKey.String(outcome(o)))
+	if o.Err != nil {
+		s.span.RecordError(o.Err)
+		s.span.SetStatus(codes.Error, o.Err.Error())
+	}
+	s.span.End()
+}
+
+// outcome names how a wait ended.
+func outcome(o ratata.WaitOutcome) string {
+	switch {
+	case errors.Is(o.Err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(o.Err, context.Canceled):
+		return "canceled"
+	case o.Err != nil:
+		return "error"
+	case o.Acquired:
+		return "acquired"
+	default:
+		return "denied"
+	}
+}
@@ -0,0 +1,97 @@
+package ratataotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vsheshjain/ratata"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// fakeTracer is an OpenTelemetry tracer that records the spans it starts.
+type fakeTracer struct {
+	noop.Tracer
+	spans []*fakeSpan
+}
+
+func (t *fakeTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+	s := &fakeSpan{name: name, attrs: cfg.Attributes()}
+	t.spans = append(t.spans, s)
+	return ctx, s
+}
+
+// fakeSpan is a span of a fakeTracer.
+type fakeSpan struct {
+	noop.Span
+	name   string
+	attrs  []attribute.KeyValue
+	errs   []error
+	status codes.Code
+	ended  bool
+}
+
+func (s *fakeSpan) SetAttributes(kv ...attribute.KeyValue)        { s.attrs = append(s.attrs, kv...) }
+func (s *fakeSpan) RecordError(err error, _ ...trace.EventOption) { s.errs = append(s.errs, err) }
+func (s *fakeSpan) SetStatus(code codes.Code, _ string)           { s.status = code }
+func (s *fakeSpan) End(...trace.SpanEndOption)                    { s.ended = true }
+
+// attr returns the value of the span's attribute key.
+func (s *fakeSpan) attr(key attribute.Key) attribute.Value {
+	for _, kv := range s.attrs {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracerRecordsSpans(t *testing.T) {
+	tracer := &fakeTracer{}
+	b := ratata.NewRatataBucket(2, time.Hour, ratata.WithTracer(Tracer(tracer)))
+	if err := b.WaitN(context.Background(), 2); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := b.WaitN(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitN = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if len(tracer.spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(tracer.spans))
+	}
+	ok, timeout := tracer.spans[0], tracer.spans[1]
+	if ok.name != "ratata.Wait" || !ok.ended || ok.attr(TokensKey).AsInt64() != 2 {
+		t.Errorf("first span %q, ended %v, %d tokens; want an ended ratata.Wait for 2", ok.name, ok.ended, ok.attr(TokensKey).AsInt64())
+	}
+	if got := ok.attr(OutcomeKey).AsString(); got != "acquired" || len(ok.errs) != 0 || ok.status != codes.Unset {
+		t.Errorf("first span outcome %q with %d errors, status %v; want acquired", got, len(ok.errs), ok.status)
+	}
+	if got := timeout.attr(OutcomeKey).AsString(); got != "timeout" || len(timeout.errs) != 1 || timeout.status != codes.Error {
+		t.Errorf("second span outcome %q with %d errors, status %v; want timeout with an error", got, len(timeout.errs), timeout.status)
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	tests := []struct {
+		outcome ratata.WaitOutcome
+		want    string
+	}{
+		{ratata.WaitOutcome{Acquired: true}, "acquired"},
+		{ratata.WaitOutcome{}, "denied"},
+		{ratata.WaitOutcome{Err: context.DeadlineExceeded}, "timeout"},
+		{ratata.WaitOutcome{Err: context.Canceled}, "canceled"},
+		{ratata.WaitOutcome{Err: ratata.ErrExceedsCapacity}, "error"},
+	}
+	for _, tt := range tests {
+		if got := outcome(tt.outcome); got != tt.want {
+			t.Errorf("outcome(%+v) = %q, want %q", tt.outcome, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+module github.com/vsheshjain/ratata/ratataprom
+
+go 1.23.1
+
+require github.com/vsheshjain/ratata v0.0.0-20261014151640-b5f148d2efa8
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/kylelemons/godebug v1.1.0 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/prometheus/client_golang v1.22.0
+	github.com/prometheus/client_model v0.6.1 // indirect
+	github.com/prometheus/common v0.62.0 // indirect
+	github.com/prometheus/procfs v0.15.1 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+	google.golang.org/protobuf v1.36.5 // indirect
+)
+
+// Build against the core in this repository. Replace directives only apply here,
+// so modules that require this one get the version required above.
+replace github.com/vsheshjain/ratata => ../
@@ -0,0 +1,135 @@
+// Package ratataprom exports ratata's admission decisions and bucket occupancy as
+// Prometheus metrics.
+package ratataprom
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vsheshjain/ratata"
+)
+
+// Collector is a prometheus.Collector of a RatataLimiter's decisions and occupancy.
+// Decisions reach it through its Observe method, registered with
+// ratata.WithOnDecision, and the occupancy gauges are read from the limiter given to
+// Watch at each scrape:
+//
+//	c := ratataprom.NewCollector("api", nil)
+//	rl := ratata.NewRatataLimiter(5, time.Second, ratata.WithOnDecision(c.Observe))
+//	c.Watch(rl)
+//	prometheus.MustRegister(c)
+//
+// It exports the counters <namespace>_allowed_total, labeled by key, and
+// <namespace>_denied_total, labeled by key and by a reason of "no_tokens", "blocked",
+// "global_cap", "degraded", "store_error" or the reserved "min_interval", and the
+// gauges <namespace>_buckets, <namespace>_tokens and <namespace>_capacity.
+type Collector struct {
+	keyLabel func(key string) string // Maps a decision's key to its label value.
+	allowed  *prometheus.CounterVec  // Allowed decisions by key label.
+	denied   *prometheus.CounterVec  // Denied decisions by key label and reason.
+	buckets  *prometheus.Desc        // Number of tracked users.
+	tokens   *prometheus.Desc        // Tokens held by all tracked users.
+	capacity *prometheus.Desc        // Capacity of all tracked users.
+
+	limiter *ratata.RatataLimiter // Limiter whose occupancy is exported, if set.
+	mu      sync.Mutex            // Mutex to protect limiter.
+}
+
+var _ prometheus.Collector = (*Collector)(nil)
+
+// NewCollector returns a Collector whose metric names start with namespace, which
+// defaults to "ratata". Each decision is counted under the label keyLabel returns for
+// its key. With many users, keyLabel should map keys to a bounded set of values, such
+// as the user's plan, as every distinct label is a separate time series; a nil
+// keyLabel labels decisions with the key itself. Decisions of the global Allow path
+// have an empty key.
+func NewCollector(namespace string, keyLabel func(key string) string) *Collector {
+	if namespace == "" {
+		namespace = "ratata"
+	}
+	if keyLabel == nil {
+		keyLabel = func(key string) string { return key }
+	}
+	return &Collector{
+		keyLabel: keyLabel,
+		allowed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "allowed_total",
+			Help:      "Number of actions allowed by the rate limiter.",
+		}, []string{"key"}),
+		denied: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "denied_total",
+			Help:      "Number of actions denied by the rate limiter.",
+		}, []string{"key", "reason"}),
+		buckets:  prometheus.NewDesc(namespace+"_buckets", "Number of users tracked by the rate limiter.", nil, nil),
+		tokens:   prometheus.NewDesc(namespace+"_tokens", "Tokens held by the buckets of all tracked users.", nil, nil),
+		capacity: prometheus.NewDesc(namespace+"_capacity", "Capacity of the buckets of all tracked users.", nil, nil),
+	}
+}
+
+// Observe counts a decision. It is meant to be registered with ratata.WithOnDecision.
+func (c *Collector) Observe(res ratata.Result) {
+	key := c.keyLabel(res.Key)
+	if res.Allowed {
+		c.allowed.WithLabelValues(key).Inc()
+	} else {
+		c.denied.WithLabelValues(key, reasonLabel(res.Reason)).Inc()
+	}
+}
+
+// reasonLabel returns the value of the reason label for a denial.
+func reasonLabel(r ratata.DenyReason) string {
+	switch r {
+	case ratata.ReasonNoTokens:
+		return "no_tokens"
+	case ratata.ReasonBlocked:
+		return "blocked"
+	case ratata.ReasonGlobalCap:
+		return "global_cap"
+	case ratata.ReasonDegraded:
+		return "degraded"
+	case ratata.ReasonStoreError:
+		return "store_error"
+	case ratata.ReasonMinInterval:
+		return "min_interval"
+	default:
+		return "unknown"
+	}
+}
+
+// Watch makes the Collector export the occupancy of rl. Until it is called, the
+// gauges are not exported.
+func (c *Collector) Watch(rl *ratata.RatataLimiter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.limiter = rl
+}
+
+// Describe sends the descriptors of the Collector's metrics to ch.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.allowed.Describe(ch)
+	c.denied.Describe(ch)
+	ch <- c.buckets
+	ch <- c.tokens
+	ch <- c.capacity
+}
+
+// Collect sends the Collector's counters, and the current occupancy of the watched
+// limiter, to ch.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.allowed.Collect(ch)
+	c.denied.Collect(ch)
+
+	c.mu.Lock()
+	rl := c.limiter
+	c.mu.Unlock()
+	if rl == nil {
+		return
+	}
+	tokens, capacity := rl.Occupancy()
+	ch <- prometheus.MustNewConstMetric(c.buckets, prometheus.GaugeValue, float64(rl.Len()))
+	ch <- prometheus.MustNewConstMetric(c.tokens, prometheus.GaugeValue, float64(tokens))
+	ch <- prometheus.MustNewConstMetric(c.capacity, prometheus.GaugeValue, float64(capacity))
+}
@@ -0,0 +1,73 @@
+package ratataprom
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/vsheshjain/ratata"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector("", nil)
+	var times []time.Time
+	rl := ratata.NewRatataLimiter(2, time.Hour,
+		ratata.WithOnDecision(func(res ratata.Result) { times = append(times, res.Time) }),
+		ratata.WithOnDecision(c.Observe))
+	c.Watch(rl)
+	rl.AllowUser("alice")
+	rl.AllowUser("alice")
+	rl.AllowUser("alice")
+	rl.AllowUser("bob")
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+	err := testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP ratata_allowed_total Number of actions allowed by the rate limiter.
+# TYPE ratata_allowed_total counter
+ratata_allowed_total{key="alice"} 2
+ratata_allowed_total{key="bob"} 1
+# HELP ratata_denied_total Number of actions denied by the rate limiter.
+# TYPE ratata_denied_total counter
+ratata_denied_total{key="alice",reason="no_tokens"} 1
+# HELP ratata_buckets Number of users tracked by the rate limiter.
+# TYPE ratata_buckets gauge
+ratata_buckets 2
+# HELP ratata_tokens Tokens held by the buckets of all tracked users.
+# TYPE ratata_tokens gauge
+ratata_tokens 1
+# HELP ratata_capacity Capacity of the buckets of all tracked users.
+# TYPE ratata_capacity gauge
+ratata_capacity 4
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every OnDecision hook sees every decision, with its time.
+	if len(times) != 4 || times[0].IsZero() {
+		t.Errorf("the other hook saw %d decisions, want 4 with their times", len(times))
+	}
+}
+
+func TestReasonLabel(t *testing.T) {
+	tests := []struct {
+		reason ratata.DenyReason
+		want   string
+	}{
+		{ratata.ReasonNoTokens, "no_tokens"},
+		{ratata.ReasonBlocked, "blocked"},
+		{ratata.ReasonGlobalCap, "global_cap"},
+		{ratata.ReasonDegraded, "degraded"},
+		{ratata.ReasonStoreError, "store_error"},
+		{ratata.ReasonMinInterval, "min_interval"},
+		{ratata.ReasonNone, "unknown"},
+	}
+	for _, tt := range tests {
+		if got := reasonLabel(tt.reason); got != tt.want {
+			t.Errorf("reasonLabel(%v) = %q, want %q", tt.reason, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+module github.com/vsheshjain/ratata/ratataredis
+
+go 1.23.1
+
+require (
+	github.com/alicebob/miniredis/v2 v2.33.0
+	github.com/redis/go-redis/v9 v9.7.3
+	github.com/vsheshjain/ratata v0.0.0-20261014151640-b5f148d2efa8
+)
+
+require (
+	github.com/alicebob/gopher-json v0.0.0-20200520072559-a9ecdc9d1d3a // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+)
+
+// Build against the core in this repository. Replace directives only apply here,
+// so modules that require this one get the version required above.
+replace github.com/vsheshjain/ratata => ../
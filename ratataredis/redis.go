@@ -0,0 +1,124 @@
+// Package ratataredis provides a ratata.Store backed by Redis, so that every
+// replica of a service shares one set of per-user buckets.
+package ratataredis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/vsheshjain/ratata"
+)
+
+// takeScript refills and takes from a bucket in one atomic step. Times are in
+// microseconds, which Lua's double-precision numbers hold exactly.
+//
+// KEYS[1] is the bucket's hash; ARGV holds n, capacity, refill rate and now.
+// It returns allowed (0 or 1), the remaining tokens and the retry delay.
+var takeScript = redis.NewScript(refill + `
+local allowed = 0
+if n <= 0 or (capacity > 0 and tokens >= n) then
+	allowed = 1
+	tokens = tokens - math.max(n, 0)
+end
+save()
+local missing = math.max(n, 1) - tokens
+local retry = 0
+if missing > 0 then
+	retry = math.max(last + missing * rate - now, 0)
+end
+return {allowed, tokens, retry}
+`)
+
+// refundScript refills a bucket and adds tokens back to it, up to its capacity.
+var refundScript = redis.NewScript(refill + `
+tokens = math.min(tokens + math.max(n, 0), capacity)
+save()
+return 1
+`)
+
+// refill is the common start of the scripts: it loads the bucket, creating it full,
+// and credits the tokens earned since its last refill. save writes it back with an
+// expiry at the time it would be full again, when it is equivalent to a new bucket.
+const refill = `
+local n, capacity, rate, now = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens, last = tonumber(state[1]), tonumber(state[2])
+if tokens == nil or last == nil then
+	tokens, last = capacity, now
+end
+tokens = math.min(tokens, capacity)
+if now > last then
+	local earned = math.floor((now - last) / rate)
+	if earned >= capacity - tokens then
+		tokens, last = capacity, now
+	else
+		tokens, last = tokens + earned, last + earned * rate
+	end
+end
+local function save()
+	redis.call('HSET', KEYS[1], 'tokens', tokens, 'last', last)
+	local full = math.max(capacity - tokens, 0) * rate + math.max(last - now, 0)
+	redis.call('PEXPIRE', KEYS[1], math.floor(full / 1000) + 1000)
+end
+`
+
+// Store is a ratata.Store that keeps each bucket in a Redis hash and refills and
+// takes from it with a Lua script, so that decisions are atomic and consistent
+// across every limiter sharing the Redis instance or cluster. Buckets expire once
+// they would be full again, so idle users cost nothing.
+//
+// Refill is computed from each request's time, the Clock of the limiter making it,
+// so replicas should keep their clocks in sync; skew shifts refill by at most the
+// amount of skew. Times are kept to the microsecond, so refill rates are rounded up
+// to at least a microsecond.
+type Store struct {
+	client redis.Scripter // Client the scripts run on.
+	prefix string         // Prefix of every bucket's key.
+}
+
+var (
+	_ ratata.Store    = (*Store)(nil)
+	_ ratata.Refunder = (*Store)(nil)
+)
+
+// New returns a Store that runs on client, which may be a *redis.Client, a
+// *redis.ClusterClient or a *redis.Ring, and keeps each bucket under prefix
+// followed by the bucket's key. A prefix such as "ratata:" keeps the limiter's keys
+// apart from others.
+func New(client redis.Scripter, prefix string) *Store {
+	return &Store{client: client, prefix: prefix}
+}
+
+// Take atomically refills the bucket stored under req.Key as of req.Now and consumes
+// req.N tokens if they are available. A missing bucket is created full.
+func (s *Store) Take(ctx context.Context, req ratata.TakeRequest) (ratata.TakeResult, error) {
+	res, err := takeScript.Run(ctx, s.client, []string{s.prefix + req.Key}, args(req)...).Int64Slice()
+	if err != nil {
+		return ratata.TakeResult{}, fmt.Errorf("ratataredis: take %q: %w", req.Key, err)
+	}
+	if len(res) != 3 {
+		return ratata.TakeResult{}, fmt.Errorf("ratataredis: take %q: unexpected script result %v", req.Key, res)
+	}
+	return ratata.TakeResult{
+		Allowed:    res[0] == 1,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Microsecond,
+	}, nil
+}
+
+// Refund atomically adds req.N tokens back to the bucket stored under req.Key,
+// refilling it as of req.Now first and never filling it beyond req.Capacity.
+func (s *Store) Refund(ctx context.Context, req ratata.TakeRequest) error {
+	if err := refundScript.Run(ctx, s.client, []string{s.prefix + req.Key}, args(req)...).Err(); err != nil {
+		return fmt.Errorf("ratataredis: refund %q: %w", req.Key, err)
+	}
+	return nil
+}
+
+// args returns the script arguments for req.
+func args(req ratata.TakeRequest) []any {
+	rate := max((req.RefillRate+time.Microsecond-1)/time.Microsecond, 1)
+	return []any{req.N, req.Capacity, int64(rate), req.Now.UnixMicro()}
+}
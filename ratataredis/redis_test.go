@@ -0,0 +1,94 @@
+package ratataredis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/vsheshjain/ratata"
+)
+
+// newStore returns a Store on a fresh miniredis server, which it also returns.
+func newStore(t *testing.T) (*Store, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return New(client, "ratata:"), mr
+}
+
+// take takes n tokens from a bucket of 2, refilled one per second, at now.
+func take(t *testing.T, s *Store, key string, n int, now time.Time) ratata.TakeResult {
+	t.Helper()
+	res, err := s.Take(context.Background(), ratata.TakeRequest{
+		Key: key, N: n, Capacity: 2, RefillRate: time.Second, Now: now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestTakeRefills(t *testing.T) {
+	s, _ := newStore(t)
+	now := time.Unix(1_000_000, 0)
+
+	if res := take(t, s, "alice", 2, now); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("first take = %+v, want allowed with 0 left", res)
+	}
+	res := take(t, s, "alice", 1, now.Add(400*time.Millisecond))
+	if res.Allowed || res.RetryAfter != 600*time.Millisecond {
+		t.Errorf("take from an empty bucket = %+v, want denied with a retry after 600ms", res)
+	}
+	if res := take(t, s, "alice", 1, now.Add(time.Second)); !res.Allowed {
+		t.Errorf("take after a refill = %+v, want allowed", res)
+	}
+	if res := take(t, s, "bob", 2, now); !res.Allowed {
+		t.Errorf("take for another key = %+v, want its own full bucket", res)
+	}
+}
+
+func TestRefundClamps(t *testing.T) {
+	s, _ := newStore(t)
+	now := time.Unix(1_000_000, 0)
+
+	take(t, s, "alice", 1, now)
+	req := ratata.TakeRequest{Key: "alice", N: 5, Capacity: 2, RefillRate: time.Second, Now: now}
+	if err := s.Refund(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if res := take(t, s, "alice", 2, now); !res.Allowed {
+		t.Errorf("take after a refund = %+v, want the full capacity of 2", res)
+	}
+	if res := take(t, s, "alice", 1, now); res.Allowed {
+		t.Errorf("take beyond the capacity = %+v, want the refund clamped to 2", res)
+	}
+}
+
+func TestTakeZeroCapacity(t *testing.T) {
+	s, _ := newStore(t)
+	res, err := s.Take(context.Background(), ratata.TakeRequest{
+		Key: "alice", N: 1, Capacity: 0, RefillRate: time.Second, Now: time.Unix(1_000_000, 0),
+	})
+	if err != nil || res.Allowed {
+		t.Errorf("take from a zero-capacity bucket = %+v, %v; want denied", res, err)
+	}
+}
+
+func TestBucketExpires(t *testing.T) {
+	s, mr := newStore(t)
+	take(t, s, "alice", 1, time.Unix(1_000_000, 0))
+	if !mr.Exists("ratata:alice") {
+		t.Fatal("the bucket wasn't stored under its prefixed key")
+	}
+	// The bucket is full again a second later, and expires a second after that.
+	mr.FastForward(1999 * time.Millisecond)
+	if !mr.Exists("ratata:alice") {
+		t.Error("the bucket expired before it was full again")
+	}
+	mr.FastForward(time.Millisecond)
+	if mr.Exists("ratata:alice") {
+		t.Error("the bucket didn't expire once it was full again")
+	}
+}
@@ -0,0 +1,40 @@
+package ratatatest
+
+import "testing"
+
+// UserLimiter is implemented by limiters that make per-user decisions, such as
+// ratata.RatataLimiter.
+type UserLimiter interface {
+	AllowUser(userID string) bool
+}
+
+// AssertAllowed calls AllowUser n times for userID and fails the test unless every
+// call is allowed.
+func AssertAllowed(t testing.TB, limiter UserLimiter, userID string, n int) {
+	t.Helper()
+
+	if got := countAllowed(limiter, userID, n); got != n {
+		t.Errorf("ratatatest: %d of %d calls allowed for user %q, want all", got, n, userID)
+	}
+}
+
+// AssertDenied calls AllowUser n times for userID and fails the test unless every
+// call is denied.
+func AssertDenied(t testing.TB, limiter UserLimiter, userID string, n int) {
+	t.Helper()
+
+	if got := countAllowed(limiter, userID, n); got != 0 {
+		t.Errorf("ratatatest: %d of %d calls allowed for user %q, want none", got, n, userID)
+	}
+}
+
+// countAllowed calls AllowUser n times and returns how many calls were allowed.
+func countAllowed(limiter UserLimiter, userID string, n int) int {
+	allowed := 0
+	for range n {
+		if limiter.AllowUser(userID) {
+			allowed++
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,47 @@
+package ratatatest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/vsheshjain/ratata"
+)
+
+// recordingTB is a testing.TB that records failures instead of failing the test.
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertions(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	rl := ratata.NewRatataLimiter(3, time.Second, ratata.WithClock(clock))
+
+	AssertAllowed(t, rl, "alice", 3)
+	AssertDenied(t, rl, "alice", 2)
+	clock.Advance(2 * time.Second)
+	AssertAllowed(t, rl, "alice", 2)
+}
+
+func TestAssertionsFail(t *testing.T) {
+	rl := ratata.NewRatataLimiter(1, time.Hour)
+
+	rec := &recordingTB{TB: t}
+	AssertAllowed(rec, rl, "alice", 2)
+	if len(rec.errors) != 1 {
+		t.Errorf("AssertAllowed reported %d errors for 1 of 2 calls allowed, want 1", len(rec.errors))
+	}
+
+	rec = &recordingTB{TB: t}
+	AssertDenied(rec, rl, "bob", 1)
+	if len(rec.errors) != 1 {
+		t.Errorf("AssertDenied reported %d errors for an allowed call, want 1", len(rec.errors))
+	}
+}
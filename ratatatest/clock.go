@@ -0,0 +1,91 @@
+// Package ratatatest provides utilities for testing code that uses ratata limiters,
+// such as a manually advanced clock and assertions on admission decisions.
+package ratatatest
+
+import (
+	"sync"
+	"time"
+)
+
+// ManualClock is a ratata.Clock whose time only moves when Advance or Set is
+// called, making refill deterministic in tests. It is safe for concurrent use.
+type ManualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter
+}
+
+// waiter is a pending After call.
+type waiter struct {
+	at time.Time      // Time at which the waiter fires.
+	ch chan time.Time // Channel the firing time is sent on.
+}
+
+// NewManualClock returns a ManualClock set to start.
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+// Now returns the clock's current time.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// After returns a channel that receives the clock's time once it has been advanced
+// by at least d. A non-positive d fires immediately.
+func (c *ManualClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, waiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward by d and fires any After channels that are due.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setLocked(c.now.Add(d))
+}
+
+// Set moves the clock to t and fires any After channels that are due. Setting the
+// clock backwards is allowed but fires nothing.
+func (c *ManualClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setLocked(t)
+}
+
+// Waiters returns the number of After channels that have not fired yet. Tests can
+// use it to wait until a goroutine is blocked on the clock before advancing it.
+func (c *ManualClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.waiters)
+}
+
+// setLocked sets the time and fires due waiters. The caller must hold c.mu.
+func (c *ManualClock) setLocked(t time.Time) {
+	c.now = t
+
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(t) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- t // Buffered, so this never blocks.
+	}
+	c.waiters = pending
+}
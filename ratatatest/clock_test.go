@@ -0,0 +1,54 @@
+package ratatatest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vsheshjain/ratata"
+)
+
+var _ ratata.Clock = (*ManualClock)(nil)
+
+func TestManualClockAfter(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	ch := clock.After(time.Second)
+	if got := clock.Waiters(); got != 1 {
+		t.Fatalf("Waiters = %d, want 1", got)
+	}
+
+	clock.Advance(999 * time.Millisecond)
+	select {
+	case <-ch:
+		t.Fatal("After fired before its duration elapsed")
+	default:
+	}
+
+	clock.Advance(time.Millisecond)
+	select {
+	case got := <-ch:
+		if want := time.Unix(1, 0); !got.Equal(want) {
+			t.Errorf("After sent %v, want %v", got, want)
+		}
+	default:
+		t.Fatal("After didn't fire once its duration elapsed")
+	}
+	if got := clock.Waiters(); got != 0 {
+		t.Errorf("Waiters = %d after firing, want 0", got)
+	}
+}
+
+func TestManualClockSet(t *testing.T) {
+	clock := NewManualClock(time.Unix(100, 0))
+	ch := clock.After(time.Minute)
+
+	clock.Set(time.Unix(50, 0))
+	if got := clock.Now(); !got.Equal(time.Unix(50, 0)) {
+		t.Errorf("Now = %v after setting it back, want %v", got, time.Unix(50, 0))
+	}
+	clock.Set(time.Unix(200, 0))
+	select {
+	case <-ch:
+	default:
+		t.Error("After didn't fire when Set moved past its time")
+	}
+}
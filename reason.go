@@ -0,0 +1,52 @@
+package ratata
+
+// DenyReason tells why an admission check denied an action.
+type DenyReason int
+
+const (
+	ReasonNone       DenyReason = iota // The action was allowed.
+	ReasonNoTokens                     // The user's bucket didn't have enough tokens.
+	ReasonBlocked                      // The user is blocked; see Block and WithAutoBlock.
+	ReasonGlobalCap                    // The limit set with WithGlobalLimit denied it.
+	ReasonDegraded                     // The tokens were there but held back by SetDegraded.
+	ReasonStoreError                   // The Store failed and the limiter fails closed.
+
+	// ReasonMinInterval is reserved for a minimum interval between a user's actions.
+	// No limiter in this package enforces one yet, so none reports it; it is defined
+	// so that code switching on reasons, such as for log or metric labels, can
+	// handle it already.
+	ReasonMinInterval
+)
+
+// String returns a short description of the reason, suitable for logs and error
+// messages.
+func (r DenyReason) String() string {
+	switch r {
+	case ReasonNone:
+		return "allowed"
+	case ReasonNoTokens:
+		return "rate limit exceeded"
+	case ReasonBlocked:
+		return "blocked"
+	case ReasonGlobalCap:
+		return "global rate limit exceeded"
+	case ReasonDegraded:
+		return "capacity reduced"
+	case ReasonStoreError:
+		return "rate limit store unavailable"
+	case ReasonMinInterval:
+		return "too soon after the previous action"
+	default:
+		return "unknown"
+	}
+}
+
+// AllowUserReason checks if an action is allowed for userID, like AllowUser, and
+// also reports why it was denied, for debugging and for error messages that tell a
+// client what to do. An allowed action reports ReasonNone. A zero-capacity bucket
+// denies with ReasonNoTokens. It is AllowUserResult reduced to its decision and
+// reason.
+func (rl *RatataLimiter) AllowUserReason(userID string) (bool, DenyReason) {
+	res := rl.AllowUserResult(userID)
+	return res.Allowed, res.Reason
+}
@@ -0,0 +1,65 @@
+package ratata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllowUserReason(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func() *RatataLimiter
+		want  DenyReason
+	}{
+		{"allowed", func() *RatataLimiter { return NewRatataLimiter(2, time.Hour) }, ReasonNone},
+		{"no tokens", func() *RatataLimiter {
+			rl := NewRatataLimiter(1, time.Hour)
+			rl.AllowUser("alice")
+			return rl
+		}, ReasonNoTokens},
+		{"zero capacity", func() *RatataLimiter { return NewRatataLimiter(0, time.Hour) }, ReasonNoTokens},
+		{"blocked", func() *RatataLimiter {
+			rl := NewRatataLimiter(2, time.Hour)
+			rl.Block("alice", time.Hour)
+			return rl
+		}, ReasonBlocked},
+		{"degraded", func() *RatataLimiter {
+			rl := NewRatataLimiter(2, time.Hour)
+			rl.SetDegraded(0)
+			return rl
+		}, ReasonDegraded},
+		{"global cap", func() *RatataLimiter {
+			rl := NewRatataLimiter(5, time.Hour, WithGlobalLimit(1, time.Hour))
+			rl.AllowUser("bob")
+			return rl
+		}, ReasonGlobalCap},
+		{"store error", func() *RatataLimiter {
+			return NewRatataLimiter(1, time.Hour, WithStore(failingStore{}))
+		}, ReasonStoreError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, reason := tt.setup().AllowUserReason("alice")
+			if ok != (tt.want == ReasonNone) || reason != tt.want {
+				t.Errorf("AllowUserReason = %v, %v; want %v", ok, reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestDenyReasonString(t *testing.T) {
+	seen := make(map[string]DenyReason)
+	for r := ReasonNone; r <= ReasonMinInterval; r++ {
+		s := r.String()
+		if s == "unknown" {
+			t.Errorf("DenyReason(%d) has no description", r)
+		}
+		if prev, ok := seen[s]; ok {
+			t.Errorf("DenyReason(%d) and DenyReason(%d) are both %q", prev, r, s)
+		}
+		seen[s] = r
+	}
+	if s := DenyReason(-1).String(); s != "unknown" {
+		t.Errorf("DenyReason(-1) = %q, want unknown", s)
+	}
+}
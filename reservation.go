@@ -0,0 +1,130 @@
+package ratata
+
+import (
+	"sync"
+	"time"
+)
+
+// Reservation records tokens charged to a bucket so that unused ones can be
+// returned precisely. A Reservation never refunds more than it charged, which
+// prevents a rollback from inflating the bucket.
+type Reservation struct {
+	bucket   *RatataBucket // Bucket the tokens were taken from.
+	ok       bool          // Whether the tokens were charged.
+	charged  int           // Number of tokens charged.
+	refunded int           // Number of tokens refunded so far.
+	readyAt  time.Time     // Time the tokens are available; zero if they already were.
+	mu       sync.Mutex    // Mutex to protect refunded.
+}
+
+// Charge consumes n tokens like AllowN and returns a Reservation that tracks them.
+// Check OK to see whether the tokens were charged. With WithPartialConsume, the
+// reservation holds whatever part of the n tokens was available.
+func (rb *RatataBucket) Charge(n int) *Reservation {
+	charged := rb.ConsumeN(n)
+	return &Reservation{bucket: rb, ok: n <= 0 || charged > 0, charged: charged}
+}
+
+// AcquireToken consumes one token like Allow and, if it was available, returns a
+// release function that refunds it, for the try-acquire/cancel pattern of a
+// semaphore: call release if the work is abandoned before completion and skip it
+// once the work is done, so the token stays consumed. release may be called any
+// number of times, from any goroutine, but refunds the token only once. When no
+// token was available, ok is false and release does nothing.
+func (rb *RatataBucket) AcquireToken() (release func(), ok bool) {
+	r := rb.Charge(1)
+	return func() { r.RefundN(1) }, r.OK()
+}
+
+// Reserve books n tokens for an action the caller will perform later, even if they
+// aren't available yet: the tokens are taken at once, driving the balance below
+// zero if need be, and Delay reports how long the caller must wait before acting
+// for the bucket to honor the booking. Other callers see the booked tokens as
+// consumed, so a booking is never overtaken. If the action is abandoned, Cancel
+// returns the tokens. The reservation is not OK, and books nothing, if n is more
+// than the bucket can ever hold above its reserve.
+func (rb *RatataBucket) Reserve(n int) *Reservation {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	now := rb.opts.clock.Now()
+	rb.refillRatata(now)
+	if n > rb.capacity-rb.opts.reserve && !rb.overdraftRatata() {
+		return &Reservation{bucket: rb}
+	}
+	n = max(n, 0)
+	rb.tokens -= n
+	rb.booked = rb.booked || rb.tokens < 0
+	r := &Reservation{bucket: rb, ok: true, charged: n}
+	if !rb.overdraftRatata() {
+		r.readyAt = now.Add(rb.delayRatata(now, rb.opts.reserve))
+	}
+	return r
+}
+
+// OK reports whether the tokens were charged.
+func (r *Reservation) OK() bool {
+	return r.ok
+}
+
+// Delay returns how long the caller must wait before acting on the reservation,
+// zero if it may act now, as is always the case for a Reservation made by Charge.
+// A reservation that isn't OK reports Never.
+func (r *Reservation) Delay() time.Duration {
+	if !r.ok {
+		return Never
+	}
+	if r.readyAt.IsZero() {
+		return 0
+	}
+	return max(r.readyAt.Sub(r.bucket.opts.clock.Now()), 0)
+}
+
+// Cancel returns all of the reservation's tokens that haven't been refunded yet, for
+// an action that was abandoned, as RefundN does. Calling it again does nothing.
+func (r *Reservation) Cancel() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if n := r.charged - r.refunded; n > 0 {
+		r.refunded += n
+		r.bucket.refund(n)
+	}
+}
+
+// Held returns the number of charged tokens that have not been refunded.
+func (r *Reservation) Held() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.charged - r.refunded
+}
+
+// RefundN returns up to n of the reservation's tokens to the bucket and reports how
+// many were returned. Requests beyond what is still held are clamped, so the total
+// refunded never exceeds what was charged. The bucket itself never goes above its
+// capacity; tokens that no longer fit because refill has since topped it up are
+// dropped.
+func (r *Reservation) RefundN(n int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n = min(n, r.charged-r.refunded)
+	if n <= 0 {
+		return 0
+	}
+	r.refunded += n
+	r.bucket.refund(n)
+	return n
+}
+
+// refund adds n tokens back to the bucket, up to its capacity plus any welcome
+// tokens not yet spent for good. Refunded welcome tokens aren't lost, but once the
+// bucket has refilled, a refund can no longer push it past its capacity.
+func (rb *RatataBucket) refund(n int) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.refillRatata(rb.opts.clock.Now())
+	rb.tokens = min(rb.tokens+n, rb.capacity+rb.welcome)
+}
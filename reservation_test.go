@@ -0,0 +1,95 @@
+package ratata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChargeRefund(t *testing.T) {
+	b := NewRatataBucket(10, time.Hour)
+	c := b.Charge(6)
+	if !c.OK() || b.Tokens() != 4 {
+		t.Fatalf("Charge(6): OK %v with %d tokens left, want true with 4", c.OK(), b.Tokens())
+	}
+
+	if got := c.RefundN(2); got != 2 || b.Tokens() != 6 {
+		t.Errorf("RefundN(2) = %d with %d tokens, want 2 with 6", got, b.Tokens())
+	}
+	if got := c.RefundN(10); got != 4 || b.Tokens() != 10 {
+		t.Errorf("RefundN(10) = %d with %d tokens, want the 4 left of the charge with 10", got, b.Tokens())
+	}
+	if got := c.RefundN(1); got != 0 {
+		t.Errorf("RefundN(1) of a fully refunded charge = %d, want 0", got)
+	}
+}
+
+func TestChargeDenied(t *testing.T) {
+	b := NewRatataBucket(10, time.Hour)
+	c := b.Charge(11)
+	if c.OK() {
+		t.Fatal("Charge(11) succeeded on a bucket of 10")
+	}
+	if got := c.RefundN(5); got != 0 || b.Tokens() != 10 {
+		t.Errorf("RefundN(5) of a denied charge = %d with %d tokens, want 0 with 10", got, b.Tokens())
+	}
+}
+
+func TestAcquireToken(t *testing.T) {
+	b := NewRatataBucket(2, time.Hour)
+	release, ok := b.AcquireToken()
+	if !ok || b.Tokens() != 1 {
+		t.Fatalf("AcquireToken = %v with %d tokens left, want success leaving 1", ok, b.Tokens())
+	}
+	release()
+	release()
+	if got := b.Tokens(); got != 2 {
+		t.Errorf("Tokens after releasing twice = %d, want one token refunded, 2", got)
+	}
+
+	b.AcquireToken()
+	b.AcquireToken() // Both kept: the tokens stay consumed.
+	release, ok = b.AcquireToken()
+	if ok {
+		t.Error("AcquireToken succeeded on an empty bucket")
+	}
+	release() // The release of a failed acquire is a no-op.
+	if got := b.Tokens(); got != 0 {
+		t.Errorf("Tokens = %d, want 0", got)
+	}
+}
+
+func TestReservation(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(5, time.Second, WithClock(clock))
+
+	r := b.Reserve(3)
+	if !r.OK() || r.Delay() != 0 || b.Tokens() != 2 {
+		t.Fatalf("Reserve(3) = %v, delay %v, %d tokens left; want immediate with 2 left", r.OK(), r.Delay(), b.Tokens())
+	}
+
+	// Booking ahead puts the bucket in debt, which later actions must wait out.
+	ahead := b.Reserve(4)
+	if !ahead.OK() || ahead.Delay() != 2*time.Second {
+		t.Errorf("Reserve(4) = %v, delay %v; want OK after 2s", ahead.OK(), ahead.Delay())
+	}
+	if got := b.Balance(); got != -2 || b.Allow() {
+		t.Errorf("Balance = %d, want -2 and no token to spare", got)
+	}
+	clock.Advance(time.Second)
+	if got := ahead.Delay(); got != time.Second {
+		t.Errorf("Delay a second later = %v, want 1s", got)
+	}
+
+	ahead.Cancel()
+	ahead.Cancel() // Cancelling twice returns the tokens once.
+	if got := b.Balance(); got != 3 {
+		t.Errorf("Balance after Cancel = %d, want 3", got)
+	}
+
+	if over := b.Reserve(6); over.OK() || over.Delay() != Never {
+		t.Errorf("Reserve(6) = %v, delay %v; want not OK beyond the capacity", over.OK(), over.Delay())
+	}
+	if got := b.Charge(1).Delay(); got != 0 {
+		t.Errorf("Charge(1).Delay() = %v, want 0", got)
+	}
+}
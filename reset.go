@@ -0,0 +1,86 @@
+package ratata
+
+import "time"
+
+// userReset is a scheduled reset of a user's bucket to full.
+type userReset struct {
+	at   time.Time                       // Time of the next reset; zero if none is scheduled.
+	next func(after time.Time) time.Time // Returns the reset after a given time, if recurring.
+}
+
+// dueLocked reports whether the reset is due as of now and, if so, moves on to the
+// next one, so that each scheduled reset is applied exactly once. The caller must
+// hold the mutex of the user's shard.
+func (r *userReset) dueLocked(now time.Time) bool {
+	if r.at.IsZero() || now.Before(r.at) {
+		return false
+	}
+	r.at = time.Time{}
+	if r.next != nil {
+		r.at = r.next(now)
+	}
+	return true
+}
+
+// ResetUserAt schedules userID's bucket to be refilled to full once when becomes
+// due, regardless of normal accrual, replacing any reset scheduled before. The reset
+// is applied lazily, on the user's first access at or after when, so no timer runs
+// for it. A user who isn't tracked yet is created with a full bucket, and the
+// schedule is dropped along with the user on eviction. A zero when cancels the
+// scheduled reset. Resets apply to in-memory buckets, not to a Store.
+func (rl *RatataLimiter) ResetUserAt(userID string, when time.Time) {
+	rl.setUserReset(userID, userReset{at: when})
+}
+
+// ResetUserSchedule makes userID's bucket refill to full on a recurring calendar,
+// such as a hard monthly reset on top of continuous refill, replacing any reset
+// scheduled before. next returns the first reset after a given time; it is called
+// with the current time now and again each time a reset is applied, so resets
+// missed while the user was idle are applied once, not once each. For example, a
+// reset at the start of each month:
+//
+//	rl.ResetUserSchedule(userID, func(after time.Time) time.Time {
+//		y, m, _ := after.Date()
+//		return time.Date(y, m+1, 1, 0, 0, 0, 0, after.Location())
+//	})
+//
+// Resets are applied lazily, as described for ResetUserAt. A nil next cancels the
+// scheduled reset. next is called with the limiter's user map locked, so it must not
+// call back into the limiter.
+func (rl *RatataLimiter) ResetUserSchedule(userID string, next func(after time.Time) time.Time) {
+	if next == nil {
+		rl.setUserReset(userID, userReset{})
+		return
+	}
+	rl.setUserReset(userID, userReset{next: next})
+}
+
+// setUserReset replaces the scheduled reset of userID with r. A recurring reset
+// without a time yet starts from the current time.
+func (rl *RatataLimiter) setUserReset(userID string, r userReset) {
+	userID = rl.key(userID)
+	s := rl.shard(userID)
+
+	s.mu.Lock()
+	now := rl.opts.clock.Now()
+	e, ok := s.users[userID]
+	if !ok {
+		e = rl.addUserLocked(s, userID, rl.newUserLimiter(userID), now)
+	}
+	if r.next != nil {
+		r.at = r.next(now)
+	}
+	e.reset = r
+	s.mu.Unlock()
+
+	if !ok {
+		rl.enforceLimits(userID)
+	}
+}
+
+// resetLimiter refills l to full if it supports it.
+func resetLimiter(l Limiter) {
+	if r, ok := l.(interface{ Reset() }); ok {
+		r.Reset()
+	}
+}
@@ -0,0 +1,48 @@
+package ratata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResetUserAt(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(3, 1000*time.Hour, WithClock(clock))
+	rl.AllowUserResultN("alice", 3)
+	rl.ResetUserAt("alice", clock.Now().Add(time.Minute))
+
+	clock.Advance(59 * time.Second)
+	if rl.AllowUser("alice") {
+		t.Error("alice was admitted before the reset")
+	}
+	clock.Advance(time.Second)
+	if !rl.AllowUserResultN("alice", 3).Allowed {
+		t.Error("alice wasn't full once the reset time passed")
+	}
+
+	// A one-off reset applies only once.
+	clock.Advance(24 * time.Hour)
+	rl.AllowUserResultN("alice", 3)
+	if rl.AllowUserResultN("alice", 1).Allowed {
+		t.Error("alice was reset a second time")
+	}
+}
+
+func TestResetUserSchedule(t *testing.T) {
+	clock := newFakeClock()
+	// Refill is far too slow to matter, so only the resets fill the bucket.
+	rl := NewRatataLimiter(3, 1000*time.Hour, WithClock(clock))
+	daily := func(after time.Time) time.Time { return after.Truncate(24 * time.Hour).Add(24 * time.Hour) }
+	rl.AllowUserResultN("alice", 3)
+	rl.ResetUserSchedule("alice", daily)
+
+	for day := range 3 {
+		clock.Advance(24 * time.Hour)
+		if !rl.PeekUser("alice") || !rl.AllowUserResultN("alice", 3).Allowed {
+			t.Fatalf("day %d: alice wasn't full after the daily reset", day)
+		}
+		if rl.AllowUser("alice") {
+			t.Fatalf("day %d: alice was admitted with an empty bucket", day)
+		}
+	}
+}
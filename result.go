@@ -0,0 +1,200 @@
+package ratata
+
+import (
+	"context"
+	"time"
+)
+
+// Result describes a single admission decision together with the state of the
+// bucket right after it was made.
+//
+// All fields come from one locked evaluation of the bucket, so they are consistent
+// with each other: an API gateway can build a complete rate-limit response, such as
+// limit, remaining and reset headers, from a single Result.
+type Result struct {
+	Key        string        // Key the decision was made for, such as a user ID.
+	Meta       any           // Metadata attached to the user with SetUserMeta, or nil.
+	Allowed    bool          // Whether the action was allowed.
+	Limit      int           // Capacity of the bucket.
+	Remaining  int           // Tokens left in the bucket after the decision.
+	RetryAfter time.Duration // Time until the next token is available, zero if one already is.
+	ResetAfter time.Duration // Time until the bucket is full again, zero if it already is.
+	Reason     DenyReason    // Why the action was denied, ReasonNone if it was allowed.
+	Time       time.Time     // When the decision was made, by the limiter's Clock.
+}
+
+// allowResult consumes n tokens if available and reports the outcome along with the
+// bucket's remaining tokens and the delay until n tokens are available again, all
+// from one locked evaluation. Only the given factor of the tokens above the reserve
+// may be used; see SetDegraded.
+func (rb *RatataBucket) allowResult(n int, factor float64) Result {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	now := rb.opts.clock.Now()
+	floor := rb.opts.reserve + heldFor(rb.capacity-rb.opts.reserve, factor)
+	allowed := rb.takeRatata(now, n, floor)
+	res := Result{
+		Allowed:    allowed,
+		Limit:      rb.capacity,
+		Remaining:  max(rb.tokens-floor, 0),
+		RetryAfter: rb.delayRatata(now, floor+max(n, 1)),
+		ResetAfter: rb.delayRatata(now, rb.capacity),
+	}
+	switch {
+	case allowed:
+	case floor > rb.opts.reserve && rb.tokens-rb.opts.reserve >= n:
+		res.Reason = ReasonDegraded // Only the held-back tokens were missing.
+	default:
+		res.Reason = ReasonNoTokens
+	}
+	return res
+}
+
+// AllowUserResult checks if an action is allowed for userID, like AllowUser, and
+// returns the decision along with the state of the user's bucket. AllowUser is
+// this method reduced to its decision.
+//
+// A blocked user is denied without touching its bucket, and RetryAfter reports the
+// time left on the block.
+//
+// With a Store configured, a store error is resolved by the fail-open policy and
+// otherwise dropped; use AllowUserCtx to see it.
+func (rl *RatataLimiter) AllowUserResult(userID string) Result {
+	return rl.AllowUserResultN(userID, 1)
+}
+
+// AllowUserResultN is like AllowUserResult but consumes n tokens at once, all or
+// nothing, for actions whose cost varies, such as payload size. RetryAfter is then
+// how long until n tokens are available; a request for more tokens than the bucket
+// holds is always denied. Limiters built by a bucket factory are charged with their
+// AllowN method.
+func (rl *RatataLimiter) AllowUserResultN(userID string, n int) Result {
+	res, _ := rl.allowUser(context.Background(), userID, n)
+	return res
+}
+
+// RetryAfterUser returns how long until userID could next be allowed, or zero if it
+// could be now, without consuming a token or counting as an access. It covers the
+// user's bucket and any block, so middleware built on AllowUser can set a
+// Retry-After header without a second admission check. Unknown users report zero,
+// as they would start with a full bucket, and so do users whose limiter, built by a
+// bucket factory, is not a RatataBucket.
+func (rl *RatataLimiter) RetryAfterUser(userID string) time.Duration {
+	userID = rl.key(userID)
+	wait := rl.blockedFor(userID)
+	if b, isBucket := rl.lookupUser(userID).(*RatataBucket); isBucket {
+		wait = max(wait, b.retryAfter())
+	}
+	return wait
+}
+
+// PeekUser reports whether AllowUser would currently allow an action for userID,
+// without consuming a token. Unlike AllowUser, it never starts tracking a user: an
+// unknown user is treated as having a full bucket, so probing many made-up IDs
+// cannot grow the limiter's memory. Neither does it count as an access. With a
+// Store configured, only blocks and the capacity are taken into account.
+func (rl *RatataLimiter) PeekUser(userID string) bool {
+	userID = rl.key(userID)
+	if rl.blockedFor(userID) > 0 {
+		return false
+	}
+	factor := rl.degradedFactor()
+	switch l := rl.lookupUser(userID).(type) {
+	case nil:
+		usable := rl.capacity - rl.opts.reserve
+		return rl.capacity > 0 && (rl.opts.overdraft || usable-heldFor(usable, factor) > 0)
+	case *RatataBucket:
+		return l.peek(factor)
+	default:
+		return l.Tokens() > 0
+	}
+}
+
+// lookupUser returns the limiter of userID, or nil if the user isn't tracked. Unlike
+// userLimiter, it neither creates the user nor counts as an access.
+func (rl *RatataLimiter) lookupUser(userID string) Limiter {
+	s := rl.shard(userID)
+	s.mu.Lock()
+	e, ok := s.users[userID]
+	if !ok {
+		s.mu.Unlock()
+		return nil
+	}
+	l, reset := e.limiter, e.reset.dueLocked(rl.opts.clock.Now())
+	s.mu.Unlock()
+
+	if reset {
+		resetLimiter(l)
+	}
+	return l
+}
+
+// peek reports whether allowResult with the given factor would allow an action.
+func (rb *RatataBucket) peek(factor float64) bool {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	rb.refillRatata(rb.opts.clock.Now())
+	floor := rb.opts.reserve + heldFor(rb.capacity-rb.opts.reserve, factor)
+	return rb.overdraftRatata() || rb.tokens-floor > 0
+}
+
+// retryAfter refills the bucket and returns how long until Allow would next succeed.
+func (rb *RatataBucket) retryAfter() time.Duration {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	now := rb.opts.clock.Now()
+	rb.refillRatata(now)
+	return rb.retryAfterRatata(now)
+}
+
+// allowUser makes an admission decision for n tokens of userID and records it.
+func (rl *RatataLimiter) allowUser(ctx context.Context, userID string, n int) (Result, error) {
+	userID = rl.key(userID)
+	res, blocked, err := rl.evaluate(ctx, userID, n)
+	rl.recordUser(res, n)
+	if !res.Allowed && !blocked && err == nil {
+		rl.noteDenial(userID, res.Reason)
+	}
+	return res, err
+}
+
+// evaluate makes an admission decision for n tokens of userID without recording it,
+// and reports whether the user was denied because it is blocked. An error means the
+// Store failed and the decision comes from the fail-open policy.
+func (rl *RatataLimiter) evaluate(ctx context.Context, userID string, n int) (res Result, blocked bool, err error) {
+	if wait := rl.blockedFor(userID); wait > 0 {
+		return Result{Key: userID, Meta: rl.userMeta(userID), Limit: rl.capacity, RetryAfter: wait, ResetAfter: wait, Reason: ReasonBlocked, Time: rl.opts.clock.Now()}, true, nil
+	}
+
+	if rl.opts.store != nil {
+		res, err = rl.storeResult(ctx, userID, n)
+	} else {
+		l := rl.userLimiter(userID)
+		res = rl.applyGlobal(userID, l, n, limiterResult(l, n, rl.degradedFactor()))
+	}
+	res.Key = userID
+	res.Meta = rl.userMeta(userID)
+	res.Time = rl.opts.clock.Now()
+	return res, false, err
+}
+
+// limiterResult makes an admission decision for n tokens with l. Buckets report
+// their state from the same locked evaluation and can only use the given factor of
+// their tokens; other limiters are queried after the decision, report their capacity
+// only if they have a Capacity method, report no delays and are not degraded.
+func limiterResult(l Limiter, n int, factor float64) Result {
+	if b, ok := l.(*RatataBucket); ok {
+		return b.allowResult(n, factor)
+	}
+	res := Result{Allowed: l.AllowN(n), Remaining: l.Tokens()}
+	if !res.Allowed {
+		res.Reason = ReasonNoTokens
+	}
+	if c, ok := l.(interface{ Capacity() int }); ok {
+		res.Limit = c.Capacity()
+	}
+	return res
+}
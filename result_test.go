@@ -0,0 +1,95 @@
+package ratata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllowUserResult(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(3, time.Second, WithClock(clock))
+
+	tests := []struct {
+		advance    time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+		resetAfter time.Duration
+	}{
+		{0, true, 2, 0, time.Second},
+		{0, true, 1, 0, 2 * time.Second},
+		{0, true, 0, time.Second, 3 * time.Second},
+		{0, false, 0, time.Second, 3 * time.Second},
+		{1500 * time.Millisecond, true, 0, 500 * time.Millisecond, 2500 * time.Millisecond},
+	}
+	for i, tt := range tests {
+		clock.Advance(tt.advance)
+		res := rl.AllowUserResult("alice")
+		if res.Key != "alice" || res.Limit != 3 || !res.Time.Equal(clock.Now()) {
+			t.Errorf("call %d: Key %q, Limit %d, Time %v; want alice, 3, %v", i, res.Key, res.Limit, res.Time, clock.Now())
+		}
+		if res.Allowed != tt.allowed || res.Remaining != tt.remaining ||
+			res.RetryAfter != tt.retryAfter || res.ResetAfter != tt.resetAfter {
+			t.Errorf("call %d: allowed %v, remaining %d, retry after %v, reset after %v; want %v, %d, %v, %v",
+				i, res.Allowed, res.Remaining, res.RetryAfter, res.ResetAfter,
+				tt.allowed, tt.remaining, tt.retryAfter, tt.resetAfter)
+		}
+		if (res.Reason == ReasonNone) != res.Allowed {
+			t.Errorf("call %d: allowed %v with reason %v", i, res.Allowed, res.Reason)
+		}
+	}
+}
+
+func TestRetryAfterUser(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(2, time.Second, WithClock(clock))
+
+	if got := rl.RetryAfterUser("unknown"); got != 0 {
+		t.Errorf("RetryAfterUser of an unknown user = %v, want 0", got)
+	}
+	if got := rl.CountTotalUsers(); got != 0 {
+		t.Errorf("RetryAfterUser created %d users, want none", got)
+	}
+
+	rl.AllowUser("alice")
+	rl.AllowUser("alice")
+	for _, tt := range []struct {
+		advance time.Duration
+		want    time.Duration
+	}{
+		{0, time.Second},
+		{400 * time.Millisecond, 600 * time.Millisecond},
+		{600 * time.Millisecond, 0},
+	} {
+		clock.Advance(tt.advance)
+		if got := rl.RetryAfterUser("alice"); got != tt.want {
+			t.Errorf("RetryAfterUser after %v = %v, want %v", tt.advance, got, tt.want)
+		}
+	}
+}
+
+func TestPeekUser(t *testing.T) {
+	rl := NewRatataLimiter(1, time.Hour)
+	if !rl.PeekUser("alice") {
+		t.Error("PeekUser of an unknown user = false, want true")
+	}
+	if got := rl.CountTotalUsers(); got != 0 {
+		t.Errorf("PeekUser created %d users, want none", got)
+	}
+
+	rl.AllowUser("alice")
+	if rl.PeekUser("alice") {
+		t.Error("PeekUser of a drained user = true, want false")
+	}
+	if got := rl.CountTotalUsers(); got != 1 {
+		t.Errorf("AllowUser created %d users, want 1", got)
+	}
+
+	rl.Block("bob", time.Hour)
+	if rl.PeekUser("bob") {
+		t.Error("PeekUser of a blocked user = true, want false")
+	}
+	if NewRatataLimiter(0, time.Hour).PeekUser("alice") {
+		t.Error("PeekUser with a capacity of zero = true, want false")
+	}
+}
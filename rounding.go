@@ -0,0 +1,63 @@
+package ratata
+
+import "time"
+
+// RoundingMode controls how a partial refill interval is turned into whole tokens.
+//
+// Whatever the mode, refill never loses time: only the time actually used to earn
+// tokens is consumed and the remainder carries over to the next refill. The mode
+// therefore decides where within each interval a token is credited, not how many
+// tokens are earned in the long run:
+//
+//   - RoundFloor credits a token at the end of its interval (strict).
+//   - RoundNearest credits it halfway through.
+//   - RoundCeil credits it as soon as the interval begins (generous).
+//
+// With RoundNearest and RoundCeil a token can be credited before its interval has
+// fully elapsed; the bucket then counts that interval as used, and the next token
+// is only credited once its own interval is reached.
+type RoundingMode int
+
+const (
+	RoundFloor   RoundingMode = iota // Only credit fully elapsed intervals. This is the default.
+	RoundNearest                     // Credit an interval once at least half of it has elapsed.
+	RoundCeil                        // Credit any interval that has started.
+)
+
+// tokensFor returns the number of tokens earned over elapsed at one token per
+// refillRate, rounded according to the mode. elapsed must be positive. The
+// partial interval is rounded separately so that even the longest elapsed time
+// can't overflow.
+func (m RoundingMode) tokensFor(elapsed, refillRate time.Duration) int {
+	whole, partial := int(elapsed/refillRate), elapsed%refillRate
+	switch {
+	case m == RoundNearest && partial >= refillRate-refillRate/2:
+		return whole + 1
+	case m == RoundCeil && partial > 0:
+		return whole + 1
+	default:
+		return whole
+	}
+}
+
+// lead returns how far past elapsed the tokens credited by tokensFor reach: the
+// rest of a partial interval that was rounded up to a whole token, or zero.
+func (m RoundingMode) lead(elapsed, refillRate time.Duration) time.Duration {
+	partial := elapsed % refillRate
+	if partial == 0 || m.tokensFor(partial, refillRate) == 0 {
+		return 0
+	}
+	return refillRate - partial
+}
+
+// creditDelay returns the shortest elapsed time after which tokensFor credits a token.
+func (m RoundingMode) creditDelay(refillRate time.Duration) time.Duration {
+	switch m {
+	case RoundNearest:
+		return refillRate - refillRate/2
+	case RoundCeil:
+		return 1
+	default:
+		return refillRate
+	}
+}
@@ -0,0 +1,120 @@
+package ratata
+
+import (
+	"testing"
+	"time"
+)
+
+// drain takes every token from b and returns how many there were.
+func drain(b *RatataBucket) int {
+	n := 0
+	for b.Allow() {
+		n++
+	}
+	return n
+}
+
+func TestRoundingModes(t *testing.T) {
+	tests := []struct {
+		mode RoundingMode
+		want int
+	}{
+		{RoundFloor, 1},
+		{RoundNearest, 2},
+		{RoundCeil, 2},
+	}
+	for _, tt := range tests {
+		clock := newFakeClock()
+		b := NewRatataBucket(10, time.Second, WithClock(clock), WithRoundingMode(tt.mode))
+		drain(b)
+		clock.Advance(1600 * time.Millisecond)
+		if got := drain(b); got != tt.want {
+			t.Errorf("mode %v: %d tokens after 1.6 intervals, want %d", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestRoundFloorCarriesRemainder(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(10, time.Second, WithClock(clock))
+	drain(b)
+
+	clock.Advance(600 * time.Millisecond)
+	if b.Allow() {
+		t.Fatal("allowed after 0.6 intervals")
+	}
+	clock.Advance(600 * time.Millisecond)
+	if !b.Allow() {
+		t.Fatal("denied after 1.2 intervals")
+	}
+	clock.Advance(800 * time.Millisecond)
+	if !b.Allow() {
+		t.Error("denied after 2 intervals: the 0.2 left over from the first token was lost")
+	}
+}
+
+func TestTokensPerInterval(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(5, 2*time.Second, WithClock(clock), WithTokensPerInterval(5))
+	drain(b)
+
+	admitted := 0
+	for range 100 { // 10s in steps of 100ms, a quarter of each token's 400ms.
+		clock.Advance(100 * time.Millisecond)
+		admitted += drain(b)
+	}
+	if admitted != 25 {
+		t.Errorf("admitted %d actions over 10s at 5 every 2s, want 25", admitted)
+	}
+
+	rl := NewRatataLimiter(5, 2*time.Second, WithClock(clock), WithTokensPerInterval(5))
+	rl.AllowUserResultN("alice", 5)
+	if res := rl.AllowUserResult("alice"); res.RetryAfter != 400*time.Millisecond {
+		t.Errorf("RetryAfter = %v, want 400ms", res.RetryAfter)
+	}
+}
+
+func TestTokensPerIntervalAccrual(t *testing.T) {
+	clock := newFakeClock()
+	// Three tokens a second don't divide a second into whole nanoseconds.
+	b := NewRatataBucket(3, time.Second, WithClock(clock), WithTokensPerInterval(3))
+	b.AllowN(3)
+	if got := b.retryAfter(); got != 333333334*time.Nanosecond {
+		t.Errorf("retryAfter = %v, want 333.333334ms", got)
+	}
+	clock.Advance(333333333)
+	if b.Allow() {
+		t.Error("a token was credited a nanosecond early")
+	}
+	clock.Advance(1)
+	if !b.Allow() {
+		t.Error("no token after a third of a second")
+	}
+	clock.Advance(666666666)
+	if !b.AllowN(2) || b.Allow() {
+		t.Error("want exactly two more tokens after the rest of the second")
+	}
+	clock.Advance(30 * time.Second)
+	if got := b.Tokens(); got != 3 {
+		t.Errorf("Tokens = %d, want the capacity of 3", got)
+	}
+}
+
+func TestTokensPerIntervalLongRun(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(10, time.Second, WithClock(clock), WithTokensPerInterval(3))
+	b.AllowN(10)
+
+	const steps = 3 * 3600 * 24
+	admitted := 0
+	for range steps {
+		clock.Advance(333333333)
+		if b.Allow() {
+			admitted++
+		}
+	}
+	// No fraction of a token is lost over a day of refills.
+	if want := int(steps * 333333333 * 3 / int64(time.Second)); admitted != want {
+		t.Errorf("admitted %d actions over a day, want %d", admitted, want)
+	}
+}
@@ -0,0 +1,125 @@
+package ratata
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// shardCount is the number of shards of a RatataLimiter's user map, a power of two.
+const shardCount = 32
+
+// userShard is one shard of a RatataLimiter's user map. Users are spread over the
+// shards by a hash of their ID, so calls for different users rarely contend on the
+// same mutex.
+type userShard struct {
+	users   map[string]*userEntry // State kept for each user of the shard.
+	mu      sync.Mutex            // Mutex to protect users and the entries in it.
+	allowed atomic.Uint64         // Number of allowed actions of the shard's users.
+	denied  atomic.Uint64         // Number of denied actions of the shard's users.
+	_       [32]byte              // Keeps neighboring shards off the same cache line.
+}
+
+// shard returns the shard holding userID, picked by an FNV-1a hash of the ID.
+func (rl *RatataLimiter) shard(userID string) *userShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(userID); i++ {
+		h ^= uint32(userID[i])
+		h *= 16777619
+	}
+	return &rl.shards[h&(shardCount-1)]
+}
+
+// rangeUsers calls fn for each tracked user, one shard at a time with that shard's
+// mutex held, so fn must neither call back into the limiter nor lock a user's bucket.
+func (rl *RatataLimiter) rangeUsers(fn func(userID string, e *userEntry)) {
+	for i := range rl.shards {
+		s := &rl.shards[i]
+		s.mu.Lock()
+		for id, e := range s.users {
+			fn(id, e)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// addUserLocked starts tracking userID in its shard s with limiter l. It never
+// evicts, as that needs the locks of other shards: the caller must hold s.mu and,
+// once it has released it, call enforceLimits.
+func (rl *RatataLimiter) addUserLocked(s *userShard, userID string, l Limiter, now time.Time) *userEntry {
+	bytes := rl.EstimateUserBytes(userID, nil)
+	if s.users == nil {
+		s.users = make(map[string]*userEntry) // Allocated on first use; see Allow.
+	}
+	e := &userEntry{limiter: l, lastAccess: now, bytes: bytes}
+	s.users[userID] = e
+	rl.userCount.Add(1)
+	rl.memory.Add(int64(bytes))
+	return e
+}
+
+// deleteLocked stops tracking userID, whose entry in its shard s is e, and drops
+// the denials counted for it towards auto-blocking. The caller must hold s.mu.
+func (rl *RatataLimiter) deleteLocked(s *userShard, userID string, e *userEntry) {
+	delete(s.users, userID)
+	rl.userCount.Add(-1)
+	rl.memory.Add(-int64(e.bytes))
+	rl.forgetDenials(userID)
+}
+
+// enforceLimits evicts users, other than keep, while the limiter tracks more users
+// than WithMaxUsers allows or is past the budget set with WithMaxMemoryBytes. The
+// caller must not hold the lock of any shard. Evictions are serialized, so
+// concurrent callers don't evict more users than needed between them.
+func (rl *RatataLimiter) enforceLimits(keep string) {
+	if !rl.overLimits() {
+		return
+	}
+
+	rl.evictMu.Lock()
+	defer rl.evictMu.Unlock()
+
+	for rl.overLimits() && rl.evictOldest(keep) {
+	}
+}
+
+// overLimits reports whether the limiter tracks more users than WithMaxUsers allows
+// or is past the budget set with WithMaxMemoryBytes.
+func (rl *RatataLimiter) overLimits() bool {
+	return (rl.opts.maxUsers > 0 && rl.userCount.Load() > int64(rl.opts.maxUsers)) ||
+		(rl.opts.maxBytes > 0 && rl.memory.Load() > int64(rl.opts.maxBytes))
+}
+
+// evictOldest removes the user with the oldest last access, other than keep, and
+// reports whether there was one. Ties are broken by the smaller user ID, so the
+// victim never depends on map iteration order and the same state always evicts the
+// same users. The shards are scanned one at a time; a victim accessed before its
+// shard is locked again is spared, and the caller simply looks again.
+func (rl *RatataLimiter) evictOldest(keep string) bool {
+	var (
+		victim string
+		oldest *userEntry
+		seen   time.Time
+	)
+	rl.rangeUsers(func(id string, e *userEntry) {
+		if id == keep {
+			return
+		}
+		if oldest == nil || e.lastAccess.Before(seen) || (e.lastAccess.Equal(seen) && id < victim) {
+			victim, oldest, seen = id, e, e.lastAccess
+		}
+	})
+	if oldest == nil {
+		return false
+	}
+
+	s := rl.shard(victim)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.users[victim] == oldest && oldest.lastAccess.Equal(seen) {
+		rl.deleteLocked(s, victim, oldest)
+		rl.opts.logger.Debugf("ratata: evicted user %q, last seen %s", victim, seen)
+	}
+	return true
+}
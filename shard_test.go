@@ -0,0 +1,90 @@
+package ratata
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// BenchmarkAllowUserParallel measures AllowUser across many users from concurrent
+// goroutines; run it with -cpu 1,2,4,8 to see how the sharded user map scales.
+func BenchmarkAllowUserParallel(b *testing.B) {
+	rl := NewRatataLimiter(1<<30, time.Nanosecond)
+	ids := make([]string, 4096)
+	for i := range ids {
+		ids[i] = "user-" + strconv.Itoa(i)
+		rl.AllowUser(ids[i])
+	}
+
+	var seed atomic.Int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int(seed.Add(1)) * 997 // Start each goroutine on different users.
+		for pb.Next() {
+			rl.AllowUser(ids[i%len(ids)])
+			i++
+		}
+	})
+}
+
+func TestShardStatsSpreadsUsers(t *testing.T) {
+	rl := NewRatataLimiter(1, time.Hour)
+	for i := 0; i < 1000; i++ {
+		rl.AllowUser(strconv.Itoa(i))
+	}
+	rl.AllowUser("0") // Denied.
+
+	stats := rl.ShardStats()
+	if len(stats) != shardCount {
+		t.Fatalf("got %d shards, want %d", len(stats), shardCount)
+	}
+	var users int
+	var allowed, denied uint64
+	for i, s := range stats {
+		if s.Users == 0 {
+			t.Errorf("shard %d has no users", i)
+		}
+		users += s.Users
+		allowed += s.Allowed
+		denied += s.Denied
+	}
+	if users != 1000 || allowed != 1000 || denied != 1 {
+		t.Errorf("got %d users, %d allowed, %d denied; want 1000, 1000, 1", users, allowed, denied)
+	}
+}
+
+func TestShardStatsShowsHotShard(t *testing.T) {
+	rl := NewRatataLimiter(1, time.Hour)
+	hot := rl.shard("hot")
+
+	// Craft 100 IDs that all hash to the same shard as "hot".
+	var ids []string
+	for i := 0; len(ids) < 100; i++ {
+		if id := "user-" + strconv.Itoa(i); rl.shard(id) == hot {
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range ids {
+		rl.AllowUser(id)
+		rl.AllowUser(id) // Denied.
+	}
+	rl.AllowUser("cold")
+
+	var hotStat ShardStat
+	var others int
+	for i, s := range rl.ShardStats() {
+		if &rl.shards[i] == hot {
+			hotStat = s
+			continue
+		}
+		others += s.Users
+	}
+	if hotStat.Users != 100 || hotStat.Allowed != 100 || hotStat.Denied != 100 {
+		t.Errorf("hot shard has %d users, %d allowed, %d denied; want 100 of each", hotStat.Users, hotStat.Allowed, hotStat.Denied)
+	}
+	if others > 1 {
+		t.Errorf("other shards have %d users, want at most 1", others)
+	}
+}
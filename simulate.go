@@ -0,0 +1,46 @@
+package ratata
+
+import (
+	"iter"
+	"time"
+)
+
+// TimedRequest is a single recorded request used when replaying traffic.
+type TimedRequest struct {
+	Time   time.Time // Time at which the request was made.
+	UserID string    // User that made the request.
+}
+
+// Decisions returns an iterator over the admission decision for each event, as if
+// the events had been sent through AllowUser at their recorded times.
+//
+// Every replay starts from a clean slate: each user gets a fresh bucket with the
+// receiver's capacity and refill rate, created full at the time of that user's first
+// event. Neither the receiver nor any live per-user bucket is touched, so the same
+// events always produce the same decisions. Events must be in chronological order.
+func (rb *RatataBucket) Decisions(events []TimedRequest) iter.Seq[bool] {
+	return func(yield func(bool) bool) {
+		buckets := make(map[string]*RatataBucket)
+		for _, ev := range events {
+			b := buckets[ev.UserID]
+			if b == nil {
+				b = newBucket(rb.capacity, rb.refillRate, rb.opts)
+				b.lastRefill = ev.Time // Seed the bucket at the user's first request.
+				buckets[ev.UserID] = b
+			}
+			if !yield(b.AllowAt(ev.Time)) {
+				return
+			}
+		}
+	}
+}
+
+// Simulate replays events and returns the admission decision for each, in order.
+// It is a convenience over Decisions for offline capacity planning.
+func (rb *RatataBucket) Simulate(events []TimedRequest) []bool {
+	decisions := make([]bool, 0, len(events))
+	for allowed := range rb.Decisions(events) {
+		decisions = append(decisions, allowed)
+	}
+	return decisions
+}
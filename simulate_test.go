@@ -0,0 +1,48 @@
+package ratata
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSimulate(t *testing.T) {
+	b := NewRatataBucket(2, time.Second)
+	start := time.Unix(0, 0)
+	events := []TimedRequest{
+		{start, "alice"}, // alice: 2 -> 1
+		{start, "alice"}, // alice: 1 -> 0
+		{start, "alice"}, // alice is empty
+		{start.Add(500 * time.Millisecond), "bob"}, // bob starts full
+		{start.Add(time.Second), "alice"},          // alice earned 1 token
+		{start.Add(time.Second), "alice"},          // and spent it
+	}
+	want := []bool{true, true, false, true, true, false}
+
+	if got := b.Simulate(events); !slices.Equal(got, want) {
+		t.Errorf("Simulate = %v, want %v", got, want)
+	}
+	if got := b.Simulate(events); !slices.Equal(got, want) {
+		t.Errorf("second Simulate = %v, want the same %v", got, want)
+	}
+	if !b.IsFull() {
+		t.Error("Simulate consumed tokens from the receiver")
+	}
+}
+
+func TestDecisionsStopsEarly(t *testing.T) {
+	b := NewRatataBucket(1, time.Second)
+	start := time.Unix(0, 0)
+	events := []TimedRequest{{start, "alice"}, {start, "alice"}, {start, "alice"}}
+
+	var got []bool
+	for allowed := range b.Decisions(events) {
+		got = append(got, allowed)
+		if len(got) == 2 {
+			break
+		}
+	}
+	if want := []bool{true, false}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
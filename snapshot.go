@@ -0,0 +1,135 @@
+package ratata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+)
+
+// SnapshotVersion is the version of the Snapshot format written by this package.
+// It changes only when a format change would make older readers misinterpret it.
+// Version 2 added each user's capacity and rate multiplier.
+const SnapshotVersion = 2
+
+// Snapshot is a serializable copy of the per-user state of a RatataLimiter.
+// Users are sorted by UserID, so identical state always encodes identically.
+type Snapshot struct {
+	Version int         `json:"version"` // Format version; zero for snapshots taken before versioning.
+	Users   []UserState `json:"users"`   // Per-user bucket state, sorted by UserID.
+}
+
+// Supported reports whether this package understands the snapshot's format version.
+// Snapshots taken before versioning have the same format as version 1.
+func (snap Snapshot) Supported() bool {
+	return snap.Version >= 0 && snap.Version <= SnapshotVersion
+}
+
+// UserState is the saved state of a single user's bucket.
+type UserState struct {
+	UserID     string        `json:"user_id"`     // User the bucket belongs to.
+	Tokens     int           `json:"tokens"`      // Tokens in the bucket at its last refill.
+	LastRefill time.Time     `json:"last_refill"` // Time of the bucket's last refill.
+	RefillRate time.Duration `json:"refill_rate"` // Duration to add one token, before the rate multiplier.
+	Capacity   int           `json:"capacity"`    // Capacity of the bucket; see Restore for older versions.
+
+	RateMultiplier float64 `json:"rate_multiplier,omitempty"` // Multiplier set with SetRateMultiplier; zero means 1.
+}
+
+// Snapshot captures the state of every tracked user whose limiter is a
+// RatataBucket; limiters built by a bucket factory that aren't buckets are skipped.
+// Each bucket is read under its own lock; the snapshot is not a single atomic view
+// across users.
+func (rl *RatataLimiter) Snapshot() Snapshot {
+	ids := make([]string, 0, rl.CountTotalUsers())
+	buckets := make(map[string]*RatataBucket, cap(ids))
+	rl.rangeUsers(func(id string, e *userEntry) {
+		if b, ok := e.limiter.(*RatataBucket); ok {
+			ids = append(ids, id)
+			buckets[id] = b
+		}
+	})
+
+	slices.Sort(ids)
+
+	snap := Snapshot{Version: SnapshotVersion, Users: make([]UserState, 0, len(ids))}
+	for _, id := range ids {
+		b := buckets[id]
+		b.mu.Lock()
+		snap.Users = append(snap.Users, UserState{
+			UserID:     id,
+			Tokens:     b.tokens,
+			LastRefill: b.lastRefill,
+			RefillRate: b.baseRate,
+			Capacity:   b.capacity,
+
+			RateMultiplier: b.multiplier,
+		})
+		b.mu.Unlock()
+	}
+	return snap
+}
+
+// Restore loads the users in snap as RatataBuckets, replacing the limiters of any
+// users already tracked. Users not in the snapshot are left untouched, and the order
+// of snap.Users doesn't matter. Time that passed since the snapshot was taken is
+// credited on each user's next access, as with any other idle period, so a service
+// restored after downtime gives each user exactly the tokens earned meanwhile, up to
+// its capacity, rather than a full bucket. Each user keeps the capacity it was saved
+// with, such as one set with SetUserLimit, and its rate multiplier; users of
+// snapshots older than version 2, which record neither, get the limiter's capacity
+// and no multiplier.
+func (rl *RatataLimiter) Restore(snap Snapshot) {
+	now := rl.opts.clock.Now()
+	for _, us := range snap.Users {
+		capacity := rl.capacity
+		if snap.Version >= 2 && us.Capacity >= 0 {
+			capacity = us.Capacity
+		}
+		b := rl.newUserBucket(us.UserID, capacity, rl.refillRate)
+		b.tokens, b.welcome = min(us.Tokens, b.capacity), 0
+		b.lastRefill = us.LastRefill
+		if us.RefillRate > 0 {
+			b.baseRate = us.RefillRate
+		}
+		if snap.Version >= 2 && us.RateMultiplier > 0 {
+			b.multiplier = us.RateMultiplier
+		}
+		b.refillRate = b.scaledRate() // b isn't shared yet, so needs no lock.
+		s := rl.shard(us.UserID)
+		s.mu.Lock()
+		if e, ok := s.users[us.UserID]; ok {
+			e.limiter = b
+		} else {
+			rl.addUserLocked(s, us.UserID, b, now)
+		}
+		s.mu.Unlock()
+	}
+	rl.enforceLimits("")
+}
+
+// SaveSnapshot writes the limiter's Snapshot to w as JSON, for persisting its state
+// on shutdown and loading it with LoadSnapshot on start.
+func (rl *RatataLimiter) SaveSnapshot(w io.Writer) error {
+	if err := json.NewEncoder(w).Encode(rl.Snapshot()); err != nil {
+		return fmt.Errorf("ratata: save snapshot: %w", err)
+	}
+	return nil
+}
+
+// LoadSnapshot reads a snapshot written by SaveSnapshot from r and restores it with
+// Restore. It returns an error, and leaves the limiter untouched, if the snapshot
+// can't be decoded or has a format version this package doesn't know, in which case
+// the error wraps ErrUnsupportedSnapshot.
+func (rl *RatataLimiter) LoadSnapshot(r io.Reader) error {
+	var snap Snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return fmt.Errorf("ratata: load snapshot: %w", err)
+	}
+	if !snap.Supported() {
+		return fmt.Errorf("ratata: load snapshot of version %d: %w", snap.Version, ErrUnsupportedSnapshot)
+	}
+	rl.Restore(snap)
+	return nil
+}
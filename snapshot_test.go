@@ -0,0 +1,124 @@
+package ratata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotIsDeterministic(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(3, time.Second, WithClock(clock))
+	for _, id := range []string{"zoe", "alice", "mia", "bob", "quinn"} {
+		rl.AllowUser(id)
+	}
+
+	first, err := json.Marshal(rl.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := json.Marshal(rl.Snapshot())
+	if !bytes.Equal(first, second) {
+		t.Fatalf("two snapshots of the same state differ:\n%s\n%s", first, second)
+	}
+
+	snap := rl.Snapshot()
+	slices.Reverse(snap.Users)
+	restored := NewRatataLimiter(3, time.Second, WithClock(clock))
+	restored.Restore(snap)
+	if got, _ := json.Marshal(restored.Snapshot()); !bytes.Equal(got, first) {
+		t.Errorf("restoring users in reverse order changed the snapshot:\n%s\n%s", got, first)
+	}
+}
+
+func TestLoadSnapshotCreditsDowntime(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(5, time.Second, WithClock(clock))
+	rl.SetUserLimit("big", 10, time.Second)
+	for range 10 {
+		rl.AllowUser("big")
+	}
+	rl.AllowUser("small")
+
+	var buf bytes.Buffer
+	if err := rl.SaveSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(3 * time.Second)
+	restored := NewRatataLimiter(5, time.Second, WithClock(clock))
+	if err := restored.LoadSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	big := restored.lookupUser("big").(*RatataBucket)
+	if got := big.Tokens(); got != 3 {
+		t.Errorf("big has %d tokens, want the 3 earned during the downtime", got)
+	}
+	if got := big.Capacity(); got != 10 {
+		t.Errorf("big has capacity %d, want the 10 it was saved with", got)
+	}
+	if got := restored.lookupUser("small").Tokens(); got != 5 {
+		t.Errorf("small has %d tokens, want 5 (full)", got)
+	}
+}
+
+func TestRestoreKeepsRateMultiplier(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(10, time.Second, WithClock(clock))
+	for range 10 {
+		rl.AllowUser("alice")
+	}
+	rl.lookupUser("alice").(*RatataBucket).SetRateMultiplier(2)
+
+	var buf bytes.Buffer
+	if err := rl.SaveSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewRatataLimiter(10, time.Second, WithClock(clock))
+	if err := restored.LoadSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	b := restored.lookupUser("alice").(*RatataBucket)
+	clock.Advance(2 * time.Second)
+	if got := b.Tokens(); got != 4 {
+		t.Errorf("got %d tokens after 2s at twice the rate, want 4", got)
+	}
+	b.SetRateMultiplier(1)
+	clock.Advance(2 * time.Second)
+	if got := b.Tokens(); got != 6 {
+		t.Errorf("got %d tokens after 2s more at the base rate, want 6", got)
+	}
+}
+
+func TestRestoreOldVersions(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(5, time.Second, WithClock(clock))
+	rl.Restore(Snapshot{Version: 1, Users: []UserState{
+		{UserID: "old", Tokens: 2, LastRefill: clock.Now(), RefillRate: time.Second, Capacity: 50, RateMultiplier: 4},
+	}})
+
+	b := rl.lookupUser("old").(*RatataBucket)
+	if got := b.Capacity(); got != 5 {
+		t.Errorf("capacity %d, want the limiter's 5 for a version 1 snapshot", got)
+	}
+	clock.Advance(time.Second)
+	if got := b.Tokens(); got != 3 {
+		t.Errorf("got %d tokens after 1s, want 3: a version 1 snapshot has no multiplier", got)
+	}
+}
+
+func TestLoadSnapshotUnsupported(t *testing.T) {
+	rl := NewRatataLimiter(5, time.Second)
+	err := rl.LoadSnapshot(strings.NewReader(`{"version":9,"users":[{"user_id":"alice"}]}`))
+	if !errors.Is(err, ErrUnsupportedSnapshot) {
+		t.Fatalf("LoadSnapshot of version 9 = %v, want ErrUnsupportedSnapshot", err)
+	}
+	if rl.lookupUser("alice") != nil {
+		t.Error("a rejected snapshot was restored")
+	}
+}
@@ -0,0 +1,164 @@
+package ratata
+
+import (
+	"slices"
+	"time"
+)
+
+// Stats is a point-in-time summary of a RatataLimiter's activity.
+type Stats struct {
+	Users   int           // Number of users currently tracked.
+	Allowed uint64        // Number of allowed actions since the limiter was created.
+	Denied  uint64        // Number of denied actions since the limiter was created.
+	Uptime  time.Duration // Time since the limiter was created.
+
+	// RejectedTokens is the total number of tokens requested by denied actions, the
+	// unmet demand, which tells many small denials apart from a few large ones.
+	// It stops at the maximum uint64 rather than wrapping around.
+	RejectedTokens uint64
+}
+
+// Stats returns the limiter's aggregate counters. It reads running totals rather
+// than visiting each user's bucket, so it is cheap enough to poll.
+func (rl *RatataLimiter) Stats() Stats {
+	return Stats{
+		Users:   rl.CountTotalUsers(),
+		Allowed: rl.allowed.Load(),
+		Denied:  rl.denied.Load(),
+		Uptime:  rl.opts.clock.Now().Sub(rl.started),
+
+		RejectedTokens: rl.rejected.Load(),
+	}
+}
+
+// FlushStats delivers the limiter's current Stats to sink, for a final report when
+// the limiter is shut down. Calls still in flight may or may not be counted, so stop
+// sending traffic to the limiter first. A nil sink is ignored.
+func (rl *RatataLimiter) FlushStats(sink func(Stats)) {
+	if sink != nil {
+		sink(rl.Stats())
+	}
+}
+
+// Stop shuts the limiter down, closing it and flushing its final Stats to the sink
+// set with WithStatsSink, if any. Only the first call flushes, so Stop is safe to
+// call from several shutdown paths. Stop should be called once traffic to the
+// limiter has stopped, and the limiter should not be used afterwards.
+func (rl *RatataLimiter) Stop() {
+	rl.Close()
+	rl.stopOnce.Do(func() {
+		rl.FlushStats(rl.opts.statsSink)
+	})
+}
+
+// Len returns the number of users currently tracked. It is CountTotalUsers.
+func (rl *RatataLimiter) Len() int {
+	return rl.CountTotalUsers()
+}
+
+// CountTotalUsers returns the number of users currently tracked, whether or not
+// they have been active recently.
+func (rl *RatataLimiter) CountTotalUsers() int {
+	return int(rl.userCount.Load())
+}
+
+// CountActiveUsers returns the number of tracked users whose last admission check
+// happened within the given window before now, an estimate of live concurrency.
+func (rl *RatataLimiter) CountActiveUsers(within time.Duration) int {
+	cutoff := rl.opts.clock.Now().Add(-within)
+	active := 0
+	rl.rangeUsers(func(_ string, e *userEntry) {
+		if !e.lastAccess.Before(cutoff) {
+			active++
+		}
+	})
+	return active
+}
+
+// UsersAbove returns the tracked users whose Utilization exceeds utilization, such as
+// 0.8 for users who have used up more than 80% of their bucket, in sorted order, for
+// alerting users who are about to hit their limit. The user map is locked only to
+// list the users; each bucket is then refilled and read under its own lock, so the
+// result is not a single atomic view across users. Users whose limiter, built by a
+// bucket factory, is not a RatataBucket are included if it has a Capacity method.
+func (rl *RatataLimiter) UsersAbove(utilization float64) []string {
+	ids := make([]string, 0, rl.CountTotalUsers())
+	limiters := make(map[string]Limiter, cap(ids))
+	rl.rangeUsers(func(id string, e *userEntry) {
+		ids = append(ids, id)
+		limiters[id] = e.limiter
+	})
+
+	slices.Sort(ids)
+
+	var above []string
+	for _, id := range ids {
+		if u, ok := limiterUtilization(limiters[id]); ok && u > utilization {
+			above = append(above, id)
+		}
+	}
+	return above
+}
+
+// Occupancy returns the total tokens held by the tracked users' limiters and their
+// total capacity, for a gauge of how full the limiter is overall. Tokens of users in
+// overdraft count as negative. Users whose limiter, built by a bucket factory, has no
+// Capacity method add their tokens but no capacity. Each limiter is read in turn, so
+// the totals are not a consistent snapshot under concurrent traffic.
+func (rl *RatataLimiter) Occupancy() (tokens, capacity int) {
+	limiters := make([]Limiter, 0, rl.CountTotalUsers())
+	rl.rangeUsers(func(_ string, e *userEntry) {
+		limiters = append(limiters, e.limiter)
+	})
+
+	for _, l := range limiters {
+		if b, ok := l.(*RatataBucket); ok {
+			tokens += b.Balance()
+		} else {
+			tokens += l.Tokens()
+		}
+		if c, ok := l.(interface{ Capacity() int }); ok {
+			capacity += c.Capacity()
+		}
+	}
+	return tokens, capacity
+}
+
+// limiterUtilization returns the utilization of l, if it can be computed.
+func limiterUtilization(l Limiter) (float64, bool) {
+	if b, ok := l.(*RatataBucket); ok {
+		return b.Utilization(), true
+	}
+	c, ok := l.(interface{ Capacity() int })
+	if !ok {
+		return 0, false
+	}
+	if c.Capacity() <= 0 {
+		return 1, true
+	}
+	return 1 - float64(l.Tokens())/float64(c.Capacity()), true
+}
+
+// ShardStat is the load on one shard of a RatataLimiter's user map.
+type ShardStat struct {
+	Users   int    // Number of users tracked by the shard.
+	Allowed uint64 // Number of allowed actions of the shard's users.
+	Denied  uint64 // Number of denied actions of the shard's users.
+}
+
+// ShardStats returns the load on each shard of the limiter's user map, so that a
+// shard that is disproportionately loaded by a few hot keys can be spotted. Users are
+// assigned to shards by a hash of their ID, and the counters cover per-user
+// decisions only, not those of the global Allow path.
+func (rl *RatataLimiter) ShardStats() []ShardStat {
+	stats := make([]ShardStat, len(rl.shards))
+	for i := range rl.shards {
+		s := &rl.shards[i]
+		s.mu.Lock()
+		stats[i].Users = len(s.users)
+		s.mu.Unlock()
+		stats[i].Allowed = s.allowed.Load()
+		stats[i].Denied = s.denied.Load()
+	}
+	return stats
+}
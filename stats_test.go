@@ -0,0 +1,86 @@
+package ratata
+
+import (
+	"math"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCountActiveUsers(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(1, time.Hour, WithClock(clock))
+	rl.AllowUser("alice")
+	rl.AllowUser("bob")
+	clock.Advance(time.Minute)
+	rl.AllowUser("carol")
+
+	if got := rl.CountTotalUsers(); got != 3 {
+		t.Errorf("CountTotalUsers = %d, want 3", got)
+	}
+	if got := rl.CountActiveUsers(30 * time.Second); got != 1 {
+		t.Errorf("CountActiveUsers(30s) = %d, want only carol", got)
+	}
+	if got := rl.CountActiveUsers(time.Minute); got != 3 {
+		t.Errorf("CountActiveUsers(1m) = %d, want 3", got)
+	}
+}
+
+func TestStatsRejectedTokens(t *testing.T) {
+	rl := NewRatataLimiter(2, time.Hour)
+	rl.AllowUserResultN("alice", 5)
+	rl.AllowUserResultN("alice", 3)
+	if s := rl.Stats(); s.RejectedTokens != 8 || s.Denied != 2 {
+		t.Errorf("Stats = %d rejected tokens in %d denials, want 8 in 2", s.RejectedTokens, s.Denied)
+	}
+
+	rl.AllowN(9) // The global path counts too.
+	if s := rl.Stats(); s.RejectedTokens != 17 || s.Denied != 3 {
+		t.Errorf("Stats = %d rejected tokens in %d denials, want 17 in 3", s.RejectedTokens, s.Denied)
+	}
+}
+
+func TestSaturatingAdd(t *testing.T) {
+	var c atomic.Uint64
+	c.Store(math.MaxUint64 - 1)
+	saturatingAdd(&c, 5)
+	if got := c.Load(); got != math.MaxUint64 {
+		t.Errorf("saturatingAdd overflowed to %d", got)
+	}
+}
+
+func TestStopFlushesStats(t *testing.T) {
+	var got []Stats
+	rl := NewRatataLimiter(2, time.Hour, WithStatsSink(func(s Stats) { got = append(got, s) }))
+	for range 5 {
+		rl.AllowUser("alice")
+	}
+	rl.Stop()
+	rl.Stop() // Flushes only once.
+
+	if len(got) != 1 {
+		t.Fatalf("Stop flushed %d times, want once", len(got))
+	}
+	if s := got[0]; s.Allowed != 2 || s.Denied != 3 || s.Users != 1 {
+		t.Errorf("flushed %d allowed, %d denied, %d users; want 2, 3, 1", s.Allowed, s.Denied, s.Users)
+	}
+	NewRatataLimiter(1, time.Second).Stop() // Without a sink there is nothing to flush.
+}
+
+func TestUsersAbove(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(10, time.Hour, WithClock(clock))
+	rl.AllowUserResultN("heavy1", 9)
+	rl.AllowUserResultN("heavy2", 10)
+	rl.AllowUserResultN("light1", 2)
+	rl.AllowUserResultN("light2", 8) // Exactly at the threshold.
+
+	if got, want := rl.UsersAbove(0.8), []string{"heavy1", "heavy2"}; !slices.Equal(got, want) {
+		t.Errorf("UsersAbove(0.8) = %v, want %v", got, want)
+	}
+	clock.Advance(5 * time.Hour)
+	if got := rl.UsersAbove(0.8); len(got) != 0 {
+		t.Errorf("UsersAbove(0.8) after refill = %v, want none", got)
+	}
+}
@@ -0,0 +1,94 @@
+package ratata
+
+import (
+	"context"
+	"time"
+)
+
+// Store holds per-user bucket state outside the process, such as in a shared
+// database, so that every instance of a service makes decisions from the same
+// buckets. Implementations must perform each Take atomically.
+type Store interface {
+	// Take refills the bucket stored under req.Key as of req.Now and consumes
+	// req.N tokens if they are available. A missing bucket is created full.
+	Take(ctx context.Context, req TakeRequest) (TakeResult, error)
+}
+
+// Refunder is implemented by a Store that can take tokens back, which lets a
+// LeaseStore return leased tokens it didn't use.
+type Refunder interface {
+	// Refund adds req.N tokens back to the bucket stored under req.Key, refilling it
+	// as of req.Now first and never filling it beyond req.Capacity.
+	Refund(ctx context.Context, req TakeRequest) error
+}
+
+// TakeRequest describes a single atomic take from a Store.
+type TakeRequest struct {
+	Key        string        // Key of the bucket, such as a user ID.
+	N          int           // Number of tokens to consume.
+	Capacity   int           // Maximum number of tokens the bucket holds.
+	RefillRate time.Duration // Duration to add one token.
+	Now        time.Time     // Time the take happens at.
+}
+
+// TakeResult is the outcome of a take from a Store.
+type TakeResult struct {
+	Allowed    bool          // Whether the tokens were consumed.
+	Remaining  int           // Tokens left in the bucket after the take.
+	RetryAfter time.Duration // Time until the next token is available, zero if one already is.
+}
+
+// AllowUserCtx checks if an action is allowed for userID, like AllowUser, bounding
+// any call to the Store by ctx. If the store fails or ctx ends first, the error is
+// returned together with the decision of the fail-open policy set with
+// WithFailOpen, so callers may either act on the error or rely on the policy.
+// Without a store the decision is made in memory and the error is always nil.
+func (rl *RatataLimiter) AllowUserCtx(ctx context.Context, userID string) (bool, error) {
+	res, err := rl.allowUser(ctx, userID, 1)
+	return res.Allowed, err
+}
+
+// AllowUserE checks if an action is allowed for userID, like AllowUser, and also
+// returns any error from a fallible backend such as a Store, so that a failure can be
+// told apart from a normal denial instead of being silently resolved. The decision
+// returned with an error comes from the fail-open policy. In memory, the error is
+// always nil. It is AllowUserCtx without a context.
+func (rl *RatataLimiter) AllowUserE(userID string) (bool, error) {
+	return rl.AllowUserCtx(context.Background(), userID)
+}
+
+// storeResult takes n tokens for userID from the Store, applying the fail-open
+// policy if the store fails.
+func (rl *RatataLimiter) storeResult(ctx context.Context, userID string, n int) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return rl.storeFailure(), err
+	}
+	tr, err := rl.opts.store.Take(ctx, TakeRequest{
+		Key:        userID,
+		N:          n,
+		Capacity:   rl.capacity,
+		RefillRate: rl.tokenRate(),
+		Now:        rl.opts.clock.Now(),
+	})
+	if err != nil {
+		return rl.storeFailure(), err
+	}
+	res := Result{
+		Allowed:    tr.Allowed,
+		Limit:      rl.capacity,
+		Remaining:  tr.Remaining,
+		RetryAfter: tr.RetryAfter,
+	}
+	if !res.Allowed {
+		res.Reason = ReasonNoTokens
+	}
+	return res, nil
+}
+
+// storeFailure returns the decision of the fail-open policy for a failed Store.
+func (rl *RatataLimiter) storeFailure() Result {
+	if rl.opts.failOpen {
+		return Result{Allowed: true, Limit: rl.capacity}
+	}
+	return Result{Limit: rl.capacity, Reason: ReasonStoreError}
+}
@@ -0,0 +1,52 @@
+package ratata
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// failingStore is a Store whose every Take fails.
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, TakeRequest) (TakeResult, error) {
+	return TakeResult{}, errors.New("store down")
+}
+
+func TestStoreErrorFailOpen(t *testing.T) {
+	rl := NewRatataLimiter(1, time.Hour, WithStore(failingStore{}), WithFailOpen(true))
+	if ok, err := rl.AllowUserCtx(context.Background(), "alice"); !ok || err == nil {
+		t.Errorf("AllowUserCtx = %v, %v; want allowed along with the store error", ok, err)
+	}
+}
+
+func TestStoreErrorFailClosed(t *testing.T) {
+	rl := NewRatataLimiter(1, time.Hour, WithStore(failingStore{}))
+	if ok, err := rl.AllowUserCtx(context.Background(), "alice"); ok || err == nil {
+		t.Errorf("AllowUserCtx = %v, %v; want denied along with the store error", ok, err)
+	}
+}
+
+func TestAllowUserCtxWithoutStore(t *testing.T) {
+	rl := NewRatataLimiter(1, time.Hour)
+	if ok, err := rl.AllowUserCtx(context.Background(), "alice"); !ok || err != nil {
+		t.Errorf("AllowUserCtx = %v, %v; want allowed without an error", ok, err)
+	}
+}
+
+func TestAllowUserE(t *testing.T) {
+	failing := NewRatataLimiter(1, time.Hour, WithStore(failingStore{}))
+	if ok, err := failing.AllowUserE("alice"); ok || err == nil {
+		t.Errorf("AllowUserE with a failing store = %v, %v; want denied with an error", ok, err)
+	}
+
+	rl := NewRatataLimiter(1, time.Hour)
+	if ok, err := rl.AllowUserE("alice"); !ok || err != nil {
+		t.Errorf("first AllowUserE = %v, %v; want allowed", ok, err)
+	}
+	// A normal denial has no error, so callers can tell it from a failure.
+	if ok, err := rl.AllowUserE("alice"); ok || err != nil {
+		t.Errorf("second AllowUserE = %v, %v; want denied without an error", ok, err)
+	}
+}
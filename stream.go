@@ -0,0 +1,85 @@
+package ratata
+
+import (
+	"context"
+	"io"
+)
+
+// RateLimitedReader returns a reader that reads from r at most as fast as limiter
+// refills, consuming one token per byte. Each Read reads at most as many bytes as the
+// bucket can hold, so a large buffer is filled over several calls, and then waits
+// until the bytes it got are paid for, so reaching the end of r costs nothing.
+// Errors from r are passed through, as are errors from waiting, such as
+// ErrWaitAttemptsExceeded, which are returned along with the bytes already read.
+func RateLimitedReader(r io.Reader, limiter *RatataBucket) io.Reader {
+	return &limitedReader{r: r, limiter: limiter}
+}
+
+// RateLimitedWriter returns a writer that writes to w at most as fast as limiter
+// refills, consuming one token per byte. Each Write is split into chunks the bucket
+// can hold, and waits for each chunk's tokens before writing it. On a short write
+// the tokens for the unwritten bytes are given back and the error is returned.
+func RateLimitedWriter(w io.Writer, limiter *RatataBucket) io.Writer {
+	return &limitedWriter{w: w, limiter: limiter}
+}
+
+// limitedReader is the reader returned by RateLimitedReader.
+type limitedReader struct {
+	r       io.Reader
+	limiter *RatataBucket
+}
+
+// Read reads into p and waits for a token for each byte read.
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return lr.r.Read(p)
+	}
+	n, err := lr.r.Read(p[:lr.limiter.chunk(len(p))])
+	if n > 0 {
+		if werr := lr.limiter.WaitN(context.Background(), n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// limitedWriter is the writer returned by RateLimitedWriter.
+type limitedWriter struct {
+	w       io.Writer
+	limiter *RatataBucket
+}
+
+// Write writes p in chunks, waiting for each chunk's tokens first.
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		want := lw.limiter.chunk(len(p) - written)
+		if err := lw.limiter.WaitN(context.Background(), want); err != nil {
+			return written, err
+		}
+		n, err := lw.w.Write(p[written : written+want])
+		written += n
+		if n < want {
+			lw.limiter.refund(want - n)
+			if err == nil {
+				err = io.ErrShortWrite
+			}
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// chunk returns how many of n tokens a single WaitN can be asked for: all of them in
+// overdraft mode, and otherwise no more than the bucket holds above its reserve.
+func (rb *RatataBucket) chunk(n int) int {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if rb.overdraftRatata() {
+		return n
+	}
+	return max(min(n, rb.capacity-rb.opts.reserve), 1)
+}
@@ -0,0 +1,105 @@
+package ratata
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// advanceWhile runs f in a goroutine, advancing clock by step whenever something is
+// waiting on it, and returns how much fake time f took.
+func advanceWhile(clock *fakeClock, step time.Duration, f func()) time.Duration {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+	start := clock.Now()
+	for {
+		select {
+		case <-done:
+			return clock.Now().Sub(start)
+		default:
+		}
+		if clock.Waiters() > 0 {
+			clock.Advance(step)
+		} else {
+			runtime.Gosched()
+		}
+	}
+}
+
+func TestRateLimitedReader(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(100, time.Millisecond, WithClock(clock))
+
+	var got []byte
+	var err error
+	elapsed := advanceWhile(clock, time.Millisecond, func() {
+		got, err = io.ReadAll(RateLimitedReader(strings.NewReader(strings.Repeat("x", 1000)), b))
+	})
+	if err != nil || len(got) != 1000 {
+		t.Fatalf("ReadAll = %d bytes, %v; want 1000 bytes", len(got), err)
+	}
+	// The first 100 bytes are paid for by the full bucket, the other 900 by refill.
+	if elapsed < 850*time.Millisecond || elapsed > 950*time.Millisecond {
+		t.Errorf("reading 1000 bytes took %v, want about 900ms", elapsed)
+	}
+}
+
+func TestRateLimitedWriter(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(100, time.Millisecond, WithClock(clock))
+
+	var buf bytes.Buffer
+	var n int
+	var err error
+	elapsed := advanceWhile(clock, time.Millisecond, func() {
+		n, err = RateLimitedWriter(&buf, b).Write(bytes.Repeat([]byte("y"), 500))
+	})
+	if err != nil || n != 500 || buf.Len() != 500 {
+		t.Fatalf("Write = %d, %v with %d bytes written; want 500", n, err, buf.Len())
+	}
+	if elapsed < 350*time.Millisecond || elapsed > 450*time.Millisecond {
+		t.Errorf("writing 500 bytes took %v, want about 400ms", elapsed)
+	}
+}
+
+// shortWriter writes at most limit bytes in all.
+type shortWriter struct{ limit int }
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	n := min(len(p), w.limit)
+	w.limit -= n
+	return n, nil
+}
+
+func TestRateLimitedWriterShortWrite(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(10, time.Hour, WithClock(clock))
+
+	n, err := RateLimitedWriter(&shortWriter{limit: 4}, b).Write(make([]byte, 10))
+	if n != 4 || !errors.Is(err, io.ErrShortWrite) {
+		t.Errorf("Write = %d, %v; want 4, %v", n, err, io.ErrShortWrite)
+	}
+	if got := b.Tokens(); got != 6 {
+		t.Errorf("bucket has %d tokens after a short write, want the 6 unwritten bytes back", got)
+	}
+}
+
+func TestRateLimitedReaderPassesErrors(t *testing.T) {
+	b := NewRatataBucket(10, time.Hour)
+	want := errors.New("read failed")
+
+	if _, err := RateLimitedReader(iotest.ErrReader(want), b).Read(make([]byte, 10)); !errors.Is(err, want) {
+		t.Errorf("Read = %v, want %v", err, want)
+	}
+	if got := b.Tokens(); got != 10 {
+		t.Errorf("bucket has %d tokens after a failed Read, want all 10", got)
+	}
+}
@@ -0,0 +1,36 @@
+package ratata
+
+import "time"
+
+// startTicker switches the bucket to ticker mode, refilling it every interval from a
+// background goroutine until Stop is called.
+func (rb *RatataBucket) startTicker(interval time.Duration) {
+	stop := make(chan struct{})
+	rb.stopTicker = stop
+
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-rb.opts.clock.After(interval):
+				rb.mu.Lock()
+				rb.advanceRatata(rb.opts.clock.Now())
+				rb.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// Stop halts the refill ticker started by WithTickerRefill, after which the bucket
+// goes back to refilling lazily on each call. It is safe to call more than once and
+// does nothing for a bucket that isn't in ticker mode.
+func (rb *RatataBucket) Stop() {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if rb.stopTicker != nil {
+		close(rb.stopTicker)
+		rb.stopTicker = nil
+	}
+}
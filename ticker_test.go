@@ -0,0 +1,42 @@
+package ratata
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTickerRefillMatchesLazy(t *testing.T) {
+	clock := newFakeClock()
+	lazy := NewRatataBucket(3, time.Second, WithClock(clock))
+	ticked := NewRatataBucket(3, time.Second, WithClock(clock), WithTickerRefill(100*time.Millisecond))
+	defer ticked.Stop()
+
+	var lazyAdmitted, tickedAdmitted int
+	for range 200 {
+		clock.BlockUntil(1)
+		clock.Advance(100 * time.Millisecond)
+		clock.BlockUntil(1) // The ticker has refilled and is waiting for the next tick.
+		if lazy.Allow() {
+			lazyAdmitted++
+		}
+		if ticked.Allow() {
+			tickedAdmitted++
+		}
+	}
+	if lazyAdmitted != tickedAdmitted {
+		t.Errorf("ticker mode admitted %d, lazy mode %d; want the same", tickedAdmitted, lazyAdmitted)
+	}
+}
+
+func TestTickerStop(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(1, time.Second, WithClock(clock), WithTickerRefill(time.Second))
+	b.Allow()
+	b.Stop()
+	b.Stop() // Safe to call twice.
+
+	clock.Advance(time.Second)
+	if !b.Allow() {
+		t.Error("a stopped bucket didn't go back to refilling lazily")
+	}
+}
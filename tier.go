@@ -0,0 +1,59 @@
+package ratata
+
+import "time"
+
+// TierConfig is the limit of one tier of users sharing a RatataLimiter, such as a
+// free, pro or enterprise plan.
+type TierConfig struct {
+	Capacity   int           // Maximum number of tokens each user's bucket can hold.
+	RefillRate time.Duration // Duration to add one token to each user's bucket.
+}
+
+// TierResolver returns the tier of userID, or false to give the user the limiter's
+// own capacity and refill rate; see WithTierResolver.
+type TierResolver func(userID string) (TierConfig, bool)
+
+// userTier returns the tier of a new user, or false if the resolver has no valid tier
+// for it.
+func (rl *RatataLimiter) userTier(userID string) (TierConfig, bool) {
+	tier, ok := rl.opts.tiers(userID)
+	if !ok {
+		return TierConfig{}, false
+	}
+	if err := validateConfig(tier.Capacity, tier.RefillRate); err != nil {
+		rl.opts.logger.Infof("ratata: ignoring invalid tier for user %q: %v", userID, err)
+		return TierConfig{}, false
+	}
+	return tier, true
+}
+
+// rescaleLimit changes the bucket's capacity and refill rate like SetLimit but keeps
+// the share of the capacity the bucket holds, so a user upgraded from a half-full
+// bucket of 10 to a capacity of 100 has 50 tokens, and a downgrade drains the bucket
+// just as proportionally; a negative balance is scaled too. A bucket whose capacity
+// was zero starts full. It reports whether the limit was different before.
+func (rb *RatataBucket) rescaleLimit(capacity int, refillRate time.Duration) (changed bool, err error) {
+	if err := validateConfig(capacity, refillRate); err != nil {
+		return false, err
+	}
+
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if capacity == rb.capacity && refillRate == rb.baseRate {
+		return false, nil
+	}
+	now := rb.opts.clock.Now()
+	rb.refillRatata(now)
+	tokens, old := rb.tokens, rb.capacity
+	switch {
+	case capacity == old:
+	case old > 0:
+		tokens = min(tokens, old) * capacity / old
+	default:
+		tokens = capacity
+	}
+	rb.setLimitRatata(now, capacity, refillRate)
+	rb.tokens = tokens
+	return true, nil
+}
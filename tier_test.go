@@ -0,0 +1,66 @@
+package ratata
+
+import (
+	"testing"
+	"time"
+)
+
+// tiers resolves "pro" to a larger tier and "broken" to an invalid one.
+func tiers(userID string) (TierConfig, bool) {
+	switch userID {
+	case "pro":
+		return TierConfig{10, time.Second}, true
+	case "broken":
+		return TierConfig{-1, time.Second}, true
+	}
+	return TierConfig{}, false
+}
+
+func TestTierResolver(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(2, time.Second, WithClock(clock), WithTierResolver(tiers))
+	for range 5 {
+		rl.AllowUser("pro")
+	}
+	if got := rl.lookupUser("pro").Tokens(); got != 5 {
+		t.Errorf("pro has %d tokens, want 5 of its tier's 10", got)
+	}
+	if rl.lookupUser("free") != nil {
+		t.Fatal("lookupUser created a user")
+	}
+
+	// Users without a tier, or with an invalid one, get the limiter's own limit.
+	rl.AllowUser("free")
+	rl.AllowUser("broken")
+	for _, id := range []string{"free", "broken"} {
+		if got := rl.lookupUser(id).Tokens(); got != 1 {
+			t.Errorf("%s has %d tokens, want 1 of the default 2", id, got)
+		}
+	}
+}
+
+func TestSetUserLimitRescales(t *testing.T) {
+	clock := newFakeClock()
+	rl := NewRatataLimiter(2, time.Second, WithClock(clock), WithTierResolver(tiers))
+	rl.AllowUserResultN("pro", 5)
+
+	// An override keeps the share of the bucket held: half of 10, then of 100.
+	if err := rl.SetUserLimit("pro", 100, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if got := rl.lookupUser("pro").Tokens(); got != 50 {
+		t.Errorf("pro has %d tokens after growing to 100, want 50", got)
+	}
+	rl.SetUserLimit("pro", 100, time.Second) // Re-applying changes nothing.
+	rl.SetUserLimit("pro", 4, time.Second)
+	if got := rl.lookupUser("pro").Tokens(); got != 2 {
+		t.Errorf("pro has %d tokens after shrinking to 4, want 2", got)
+	}
+
+	// A bucket of zero capacity holds no share, so growing it again starts it full.
+	rl.SetUserLimit("zero", 0, time.Second)
+	rl.SetUserLimit("zero", 3, time.Second)
+	if got := rl.lookupUser("zero").Tokens(); got != 3 {
+		t.Errorf("zero has %d tokens, want 3", got)
+	}
+}
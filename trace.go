@@ -0,0 +1,28 @@
+package ratata
+
+import (
+	"context"
+	"time"
+)
+
+// Tracer starts spans around blocking waits; see WithTracer. It lets rate-limit
+// latency be traced without this package depending on a tracing library.
+type Tracer interface {
+	// StartWait is called when a wait for n tokens begins and returns the span that
+	// covers it. ctx is the caller's context, which may carry a parent span, and op
+	// names the waiting method, such as "Wait" or "AllowUserN".
+	StartWait(ctx context.Context, op string, n int) WaitSpan
+}
+
+// WaitSpan is a span started by a Tracer.
+type WaitSpan interface {
+	// End is called exactly once, when the wait is over.
+	End(outcome WaitOutcome)
+}
+
+// WaitOutcome describes how a blocking wait ended.
+type WaitOutcome struct {
+	Waited   time.Duration // How long the caller blocked, as measured by the Clock.
+	Acquired bool          // Whether the tokens were acquired.
+	Err      error         // Error the wait returned, such as context.DeadlineExceeded, or nil.
+}
@@ -0,0 +1,80 @@
+package ratata
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordTracer is a Tracer that records the waits it traces.
+type recordTracer struct {
+	mu       sync.Mutex    // Mutex to protect the fields below.
+	ops      []string      // Operation of each started span.
+	outcomes []WaitOutcome // Outcome of each ended span.
+}
+
+func (rt *recordTracer) StartWait(ctx context.Context, op string, n int) WaitSpan {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.ops = append(rt.ops, op)
+	return recordSpan{rt}
+}
+
+// recordSpan is a span of a recordTracer.
+type recordSpan struct{ rt *recordTracer }
+
+func (s recordSpan) End(o WaitOutcome) {
+	s.rt.mu.Lock()
+	defer s.rt.mu.Unlock()
+	s.rt.outcomes = append(s.rt.outcomes, o)
+}
+
+func TestTracerRecordsWaits(t *testing.T) {
+	clock := newFakeClock()
+	tracer := &recordTracer{}
+	b := NewRatataBucket(1, time.Second, WithClock(clock), WithTracer(tracer))
+	b.Allow()
+
+	done := make(chan error)
+	go func() { done <- b.Wait(context.Background()) }()
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := b.WaitN(ctx, 1); err != context.DeadlineExceeded {
+		t.Fatalf("WaitN = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if len(tracer.outcomes) != 2 {
+		t.Fatalf("got %d spans, want 2", len(tracer.outcomes))
+	}
+	if o := tracer.outcomes[0]; !o.Acquired || o.Waited != time.Second || o.Err != nil {
+		t.Errorf("first wait = %+v, want acquired after 1s", o)
+	}
+	if o := tracer.outcomes[1]; o.Acquired || o.Err != context.DeadlineExceeded {
+		t.Errorf("second wait = %+v, want a timeout", o)
+	}
+}
+
+func TestTracerRecordsAllowUserN(t *testing.T) {
+	tracer := &recordTracer{}
+	// Deadlines are compared with the limiter's clock, so start it at the real time.
+	rl := NewRatataLimiter(1, time.Hour, WithClock(&fakeClock{now: time.Now()}), WithTracer(tracer))
+	rl.AllowUserN(context.Background(), "alice", 1)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if ok, err := rl.AllowUserN(ctx, "alice", 1); ok || err != context.DeadlineExceeded {
+		t.Fatalf("AllowUserN = %v, %v; want %v", ok, err, context.DeadlineExceeded)
+	}
+
+	if len(tracer.outcomes) != 2 || tracer.ops[1] != "AllowUserN" {
+		t.Fatalf("got spans %v, want two for AllowUserN", tracer.ops)
+	}
+	if !tracer.outcomes[0].Acquired || tracer.outcomes[1].Err == nil {
+		t.Errorf("outcomes = %+v, want acquired and then an error", tracer.outcomes)
+	}
+}
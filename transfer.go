@@ -0,0 +1,77 @@
+package ratata
+
+import "errors"
+
+// TransferTokens atomically moves n tokens from fromUser's bucket to toUser's, for
+// quota sharing such as team pools or gifting. It fails, leaving both buckets
+// unchanged, if fromUser has fewer than n tokens available. The receiving bucket is
+// clamped to its capacity, so tokens that don't fit are lost.
+//
+// Users that aren't tracked yet are created with an empty bucket, rather than the
+// full one AllowUser would create, so a transfer can't conjure tokens. Both buckets
+// are locked in user ID order, so concurrent transfers in opposite directions
+// cannot deadlock. It returns errors.ErrUnsupported if either user's limiter, built
+// by a bucket factory, isn't a RatataBucket.
+func (rl *RatataLimiter) TransferTokens(fromUser, toUser string, n int) (bool, error) {
+	fromUser, toUser = rl.key(fromUser), rl.key(toUser)
+	switch {
+	case n < 0:
+		return false, ErrInvalidTokenCount
+	case fromUser == toUser:
+		return false, ErrSameUser
+	}
+
+	from, err := rl.transferBucket(fromUser)
+	if err != nil {
+		return false, err
+	}
+	to, err := rl.transferBucket(toUser)
+	if err != nil {
+		return false, err
+	}
+
+	// Lock in a consistent order to avoid deadlock.
+	first, second := from, to
+	if toUser < fromUser {
+		first, second = to, from
+	}
+	first.mu.Lock()
+	defer first.mu.Unlock()
+	second.mu.Lock()
+	defer second.mu.Unlock()
+
+	now := rl.opts.clock.Now()
+	from.refillRatata(now)
+	to.refillRatata(now)
+
+	if from.tokens-from.opts.reserve < n {
+		return false, nil
+	}
+	from.tokens -= n
+	to.tokens = max(to.tokens, min(to.tokens+n, to.capacity)) // Keep any welcome burst.
+	return true, nil
+}
+
+// transferBucket returns userID's bucket, creating an empty one for a new user.
+func (rl *RatataLimiter) transferBucket(userID string) (*RatataBucket, error) {
+	s := rl.shard(userID)
+	s.mu.Lock()
+	e, ok := s.users[userID]
+	if !ok {
+		l := rl.newUserLimiter(userID)
+		if b, ok := l.(*RatataBucket); ok {
+			b.tokens = 0 // Not shared yet, so no need to lock.
+		}
+		e = rl.addUserLocked(s, userID, l, rl.opts.clock.Now())
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		rl.enforceLimits(userID)
+	}
+	b, ok := e.limiter.(*RatataBucket)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return b, nil
+}
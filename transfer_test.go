@@ -0,0 +1,50 @@
+package ratata
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTransferTokens(t *testing.T) {
+	rl := NewRatataLimiter(5, time.Hour)
+	rl.AllowUser("alice") // 4 left; bob isn't tracked yet.
+
+	if ok, err := rl.TransferTokens("alice", "bob", 3); !ok || err != nil {
+		t.Fatalf("TransferTokens(3) = %v, %v; want success", ok, err)
+	}
+	if a, b := rl.lookupUser("alice").Tokens(), rl.lookupUser("bob").Tokens(); a != 1 || b != 3 {
+		t.Errorf("after the transfer alice has %d and bob %d tokens, want 1 and 3", a, b)
+	}
+
+	if ok, err := rl.TransferTokens("alice", "bob", 2); ok || err != nil {
+		t.Errorf("TransferTokens(2) from 1 token = %v, %v; want false", ok, err)
+	}
+	if a, b := rl.lookupUser("alice").Tokens(), rl.lookupUser("bob").Tokens(); a != 1 || b != 3 {
+		t.Errorf("after a failed transfer alice has %d and bob %d tokens, want 1 and 3 unchanged", a, b)
+	}
+}
+
+func TestTransferTokensConcurrent(t *testing.T) {
+	rl := NewRatataLimiter(5, time.Hour)
+	rl.AllowUser("alice")
+	rl.TransferTokens("alice", "bob", 2)
+
+	var wg sync.WaitGroup
+	for range 1000 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			rl.TransferTokens("alice", "bob", 1)
+		}()
+		go func() {
+			defer wg.Done()
+			rl.TransferTokens("bob", "alice", 1)
+		}()
+	}
+	wg.Wait() // Finishing at all shows opposite transfers don't deadlock.
+
+	if total := rl.lookupUser("alice").Tokens() + rl.lookupUser("bob").Tokens(); total != 4 {
+		t.Errorf("alice and bob hold %d tokens together, want the 4 they started with", total)
+	}
+}
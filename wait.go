@@ -0,0 +1,196 @@
+package ratata
+
+import (
+	"context"
+	"time"
+)
+
+// Wait blocks until a token is available and consumes it. It returns ctx.Err() if
+// the context is canceled or its deadline passes first, and ErrWaitAttemptsExceeded
+// if a limit set with WithWaitMaxAttempts is reached. No token is consumed when
+// Wait returns an error.
+func (rb *RatataBucket) Wait(ctx context.Context) error {
+	return rb.waitN(ctx, 1)
+}
+
+// WaitN blocks until n tokens are available and consumes them all at once, like
+// Wait. It returns ErrExceedsCapacity right away if n is more than the bucket can
+// ever hold above its reserve.
+//
+// Tokens are only taken at the moment all n are available; while waiting, WaitN
+// holds no tokens and reserves no future capacity. If ctx ends mid-wait, the bucket
+// is therefore exactly as it would be had WaitN never been called, and other
+// callers, including other waiters, are not held up by the abandoned wait.
+func (rb *RatataBucket) WaitN(ctx context.Context, n int) error {
+	return rb.waitN(ctx, n)
+}
+
+// NextAllowIn returns how long until Allow would next succeed, zero if it would now,
+// so that producers can pace themselves without polling. It doesn't consume a token.
+// A bucket that can never allow an action, such as one with a capacity of zero,
+// reports Never.
+func (rb *RatataBucket) NextAllowIn() time.Duration {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	now := rb.opts.clock.Now()
+	rb.refillRatata(now)
+	switch {
+	case rb.overdraftRatata():
+		return 0
+	case rb.capacity-rb.opts.reserve <= 0:
+		return Never
+	}
+	return rb.retryAfterRatata(now)
+}
+
+// ScheduleAllow calls fn on a new goroutine once Allow would succeed, after the delay
+// reported by NextAllowIn, without consuming a token; fn typically calls Allow
+// itself. The delay is checked again after each sleep, so changes to the bucket's
+// rate or capacity, and tokens consumed by others in the meantime, are respected.
+// The goroutine gives up without calling fn once ctx ends, or if the bucket reports
+// Never, since it would otherwise wait forever.
+func (rb *RatataBucket) ScheduleAllow(ctx context.Context, fn func()) {
+	go func() {
+		for ctx.Err() == nil {
+			delay := rb.NextAllowIn()
+			switch delay {
+			case 0:
+				fn()
+				return
+			case Never:
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-rb.opts.clock.After(delay):
+			}
+		}
+	}()
+}
+
+// waitN blocks until n tokens are available and consumes them all at once. Rather
+// than polling, it sleeps for exactly as long as refill needs to produce the missing
+// tokens and then checks again.
+func (rb *RatataBucket) waitN(ctx context.Context, n int) (err error) {
+	if t := rb.opts.tracer; t != nil {
+		span, start := t.StartWait(ctx, "Wait", n), rb.opts.clock.Now()
+		defer func() {
+			span.End(WaitOutcome{Waited: rb.opts.clock.Now().Sub(start), Acquired: err == nil, Err: err})
+		}()
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err // Don't take tokens for a caller that has already given up.
+		}
+		rb.mu.Lock()
+		if n > rb.capacity-rb.opts.reserve && !rb.overdraftRatata() {
+			rb.mu.Unlock()
+			return ErrExceedsCapacity // Refill could never satisfy the request.
+		}
+		now := rb.opts.clock.Now()
+		if rb.allowNRatata(now, n) {
+			rb.mu.Unlock()
+			return nil
+		}
+		delay := rb.delayRatata(now, n+rb.opts.reserve)
+		rb.mu.Unlock()
+
+		if limit := rb.opts.waitAttempts; limit >= 0 && attempt >= limit {
+			return ErrWaitAttemptsExceeded
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-rb.opts.clock.After(delay):
+		}
+	}
+}
+
+// delayRatata returns how long after now the bucket will hold n tokens, or zero if it
+// already does. The caller must hold rb.mu and have refilled the bucket as of now.
+func (rb *RatataBucket) delayRatata(now time.Time, n int) time.Duration {
+	missing := n - rb.tokens
+	if missing <= 0 {
+		return 0
+	}
+	// The next token is credited after the rounding mode's delay; each one after it
+	// takes a full refill interval.
+	next := rb.carry + rb.opts.rounding.creditDelay(rb.refillRate)
+	units := next + time.Duration(missing-1)*rb.refillRate
+	k := rb.opts.perInterval
+	ready := rb.lastRefill.Add((units + k - 1) / k) // Round up so the wait is never too short.
+	return max(ready.Sub(now), 0)
+}
+
+// AllowUserN consumes n tokens for userID, all or nothing, waiting for refill if
+// they aren't available yet, for cost-weighted admission with bounded latency. It
+// gives up with ctx.Err() once ctx ends, and returns context.DeadlineExceeded right
+// away if the tokens won't be available before ctx's deadline, as measured by the
+// limiter's Clock. A request for more tokens than the user's bucket can hold fails
+// with ErrExceedsCapacity. The user's bucket is created on first use, as with
+// AllowUser, and only the final outcome counts as a decision, so waiting doesn't
+// count towards auto-blocking. With a Store, a store error ends the wait and is
+// returned along with the decision of the fail-open policy.
+func (rl *RatataLimiter) AllowUserN(ctx context.Context, userID string, n int) (allowed bool, err error) {
+	if t := rl.opts.tracer; t != nil {
+		span, start := t.StartWait(ctx, "AllowUserN", n), rl.opts.clock.Now()
+		defer func() {
+			span.End(WaitOutcome{Waited: rl.opts.clock.Now().Sub(start), Acquired: allowed, Err: err})
+		}()
+	}
+
+	userID = rl.key(userID)
+	if rl.exceedsCapacity(userID, n) {
+		rl.recordUser(Result{Key: userID}, n)
+		return false, ErrExceedsCapacity
+	}
+
+	for {
+		res, blocked, err := rl.evaluate(ctx, userID, n)
+		if res.Allowed || err != nil {
+			rl.recordUser(res, n)
+			return res.Allowed, err
+		}
+
+		wait := res.RetryAfter
+		if wait <= 0 {
+			wait = rl.tokenRate() // The limiter reports no delay; check again after a refill.
+		}
+		if deadline, ok := ctx.Deadline(); ok && rl.opts.clock.Now().Add(wait).After(deadline) {
+			err = context.DeadlineExceeded
+		} else {
+			select {
+			case <-ctx.Done():
+				err = ctx.Err()
+			case <-rl.opts.clock.After(wait):
+				continue
+			}
+		}
+
+		rl.recordUser(res, n)
+		if !blocked {
+			rl.noteDenial(userID, res.Reason)
+		}
+		return false, err
+	}
+}
+
+// exceedsCapacity reports whether n tokens are more than userID's bucket, or the
+// limiter's capacity with a Store, can ever supply.
+func (rl *RatataLimiter) exceedsCapacity(userID string, n int) bool {
+	if rl.opts.store != nil {
+		return n > rl.capacity
+	}
+	switch l := rl.userLimiter(userID).(type) {
+	case *RatataBucket:
+		return l.chunk(n) < n
+	case interface{ Capacity() int }:
+		return n > l.Capacity()
+	default:
+		return false
+	}
+}
@@ -0,0 +1,225 @@
+package ratata
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitMaxAttemptsZero(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(1, time.Second, WithClock(clock), WithWaitMaxAttempts(0))
+	b.Allow()
+	if err := b.Wait(context.Background()); !errors.Is(err, ErrWaitAttemptsExceeded) {
+		t.Errorf("Wait on an empty bucket = %v, want ErrWaitAttemptsExceeded at once", err)
+	}
+}
+
+func TestWaitMaxAttemptsSucceeds(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(1, time.Second, WithClock(clock), WithWaitMaxAttempts(2))
+	b.Allow()
+
+	done := make(chan error)
+	go func() { done <- b.Wait(context.Background()) }()
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Errorf("Wait = %v, want success after one refill", err)
+	}
+}
+
+func TestWaitMaxAttemptsGivesUp(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(1, time.Second, WithClock(clock), WithWaitMaxAttempts(2))
+	b.Allow()
+
+	done := make(chan error)
+	go func() { done <- b.Wait(context.Background()) }()
+	for range 2 {
+		clock.BlockUntil(1)
+		// Take the refilled token before the waiter, which wakes up blocked on b.mu.
+		b.mu.Lock()
+		clock.Advance(time.Second)
+		if !b.allowRatata(clock.Now()) {
+			t.Fatal("no token after a refill interval")
+		}
+		b.mu.Unlock()
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrWaitAttemptsExceeded) {
+			t.Errorf("Wait = %v after 2 failed attempts, want ErrWaitAttemptsExceeded", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait kept waiting after 2 failed attempts")
+	}
+}
+
+func TestWaitContextDeadline(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(1, time.Second, WithClock(clock))
+	b.Allow()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := b.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestAllowUserN(t *testing.T) {
+	// Deadlines are compared with the limiter's clock, so start it at the real time.
+	clock := &fakeClock{now: time.Now()}
+	rl := NewRatataLimiter(10, 100*time.Millisecond, WithClock(clock))
+	ctx := context.Background()
+
+	if ok, err := rl.AllowUserN(ctx, "alice", 8); !ok || err != nil {
+		t.Fatalf("AllowUserN(8) from a new user = %v, %v; want immediate success", ok, err)
+	}
+	if ok, err := rl.AllowUserN(ctx, "alice", 11); ok || !errors.Is(err, ErrExceedsCapacity) {
+		t.Errorf("AllowUserN(11) = %v, %v; want %v", ok, err, ErrExceedsCapacity)
+	}
+
+	// Three more tokens take 300ms, longer than the deadline allows.
+	short, cancel := context.WithDeadline(ctx, clock.Now().Add(200*time.Millisecond))
+	defer cancel()
+	if ok, err := rl.AllowUserN(short, "alice", 5); ok || err != context.DeadlineExceeded {
+		t.Errorf("AllowUserN(5) with a 200ms deadline = %v, %v; want %v", ok, err, context.DeadlineExceeded)
+	}
+	if got := rl.userLimiter("alice").Tokens(); got != 2 {
+		t.Errorf("alice has %d tokens after giving up, want 2 left untouched", got)
+	}
+
+	long, cancel := context.WithDeadline(ctx, clock.Now().Add(10*time.Second))
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		ok, err := rl.AllowUserN(long, "alice", 5)
+		if err == nil && !ok {
+			err = errors.New("denied")
+		}
+		done <- err
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(300 * time.Millisecond)
+	if err := <-done; err != nil {
+		t.Errorf("AllowUserN(5) with a 10s deadline = %v, want success after refill", err)
+	}
+	if s := rl.Stats(); s.Allowed != 2 || s.Denied != 2 {
+		t.Errorf("Stats = %d allowed, %d denied; want 2, 2", s.Allowed, s.Denied)
+	}
+}
+
+func TestNextAllowIn(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(2, time.Second, WithClock(clock))
+	if got := b.NextAllowIn(); got != 0 {
+		t.Errorf("NextAllowIn of a full bucket = %v, want 0", got)
+	}
+	b.AllowN(2)
+	clock.Advance(300 * time.Millisecond)
+	if got := b.NextAllowIn(); got != 700*time.Millisecond {
+		t.Errorf("NextAllowIn = %v, want the remaining 700ms of the interval", got)
+	}
+	if got := NewRatataBucket(0, time.Second).NextAllowIn(); got != Never {
+		t.Errorf("NextAllowIn of a zero-capacity bucket = %v, want Never", got)
+	}
+}
+
+func TestScheduleAllow(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(1, time.Second, WithClock(clock))
+	b.Allow()
+
+	fired := make(chan struct{})
+	b.ScheduleAllow(context.Background(), func() { close(fired) })
+	clock.BlockUntil(1)
+	clock.Advance(999 * time.Millisecond)
+	clock.BlockUntil(1) // Sleeping again, for the last millisecond.
+	select {
+	case <-fired:
+		t.Fatal("fn was called before a token was available")
+	default:
+	}
+	clock.Advance(time.Millisecond)
+	<-fired
+	if !b.Allow() {
+		t.Error("Allow failed once ScheduleAllow fired")
+	}
+}
+
+func TestScheduleAllowGivesUp(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(1, time.Second, WithClock(clock))
+	b.Allow()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fired := make(chan struct{})
+	b.ScheduleAllow(ctx, func() { close(fired) })
+	clock.BlockUntil(1)
+	cancel()
+	clock.Advance(time.Second)
+
+	zero := NewRatataBucket(0, time.Second, WithClock(clock))
+	zero.ScheduleAllow(context.Background(), func() { close(fired) })
+	select {
+	case <-fired:
+		t.Error("fn was called after ctx ended or for a bucket that reports Never")
+	case <-time.After(10 * time.Millisecond):
+	}
+	if n := clock.Waiters(); n != 0 {
+		t.Errorf("%d goroutines still waiting, want none", n)
+	}
+}
+
+func TestWaitNCancelKeepsTokens(t *testing.T) {
+	clock := newFakeClock()
+	b := NewRatataBucket(4, time.Second, WithClock(clock))
+	b.AllowN(3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error)
+	go func() { errc <- b.WaitN(ctx, 4) }()
+	clock.BlockUntil(1)
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("WaitN = %v, want %v", err, context.Canceled)
+	}
+	if got := b.Tokens(); got != 1 {
+		t.Fatalf("Tokens after a cancelled WaitN = %d, want 1 left untouched", got)
+	}
+
+	// Another waiter needs one more token, due a second from now, with nothing held
+	// back for the cancelled wait.
+	start := clock.Now()
+	done := make(chan error)
+	go func() { done <- b.WaitN(context.Background(), 2) }()
+	clock.BlockUntil(2) // The cancelled wait's timer is still pending.
+	clock.Advance(999 * time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("WaitN returned %v after %v, want it to wait 1s", err, clock.Now().Sub(start))
+	default:
+	}
+	clock.Advance(time.Millisecond)
+	if err := <-done; err != nil {
+		t.Fatalf("second WaitN = %v, want success after 1s", err)
+	}
+	if got := b.Tokens(); got != 0 {
+		t.Errorf("Tokens = %d, want 0", got)
+	}
+}
+
+func TestWaitCancelledContext(t *testing.T) {
+	b := NewRatataBucket(4, time.Second, WithClock(newFakeClock()))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := b.Wait(ctx); err != context.Canceled {
+		t.Errorf("Wait with a cancelled context = %v, want %v", err, context.Canceled)
+	}
+	if got := b.Tokens(); got != 4 {
+		t.Errorf("Tokens after Wait with a cancelled context = %d, want 4", got)
+	}
+}
@@ -0,0 +1,140 @@
+package ratata
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// SlidingWindowLimiter is a Limiter that admits at most limit actions in any window
+// of the given length, keeping a log of the times of the actions in the current
+// window. Unlike a fixed window, it never admits a burst of twice the limit around a
+// window boundary, and unlike a token bucket it doesn't let a burst of the limit
+// through again until the first action of the previous burst has left the window.
+type SlidingWindowLimiter struct {
+	limit       int           // Maximum number of actions in any window.
+	window      time.Duration // Length of the window.
+	perInterval time.Duration // Tokens per refill rate given to SetLimit.
+	log         []time.Time   // Times of the actions in the window, oldest first.
+	clock       Clock         // Source of time.
+	mu          sync.Mutex    // Mutex to protect the fields above.
+}
+
+var _ Limiter = (*SlidingWindowLimiter)(nil)
+
+// NewSlidingWindowLimiter returns a SlidingWindowLimiter that admits up to limit
+// actions per window. Of the options, only WithClock and, for SetLimit,
+// WithTokensPerInterval apply.
+func NewSlidingWindowLimiter(limit int, window time.Duration, opts ...Option) *SlidingWindowLimiter {
+	return newSlidingWindowLimiter(limit, window, newOptions(opts))
+}
+
+// newSlidingWindowLimiter returns a SlidingWindowLimiter with an empty log, using
+// already-resolved options.
+func newSlidingWindowLimiter(limit int, window time.Duration, o options) *SlidingWindowLimiter {
+	return &SlidingWindowLimiter{
+		limit:       limit,
+		window:      window,
+		perInterval: o.perInterval,
+		clock:       o.clock,
+	}
+}
+
+// Allow admits one action if fewer than the limit were admitted within the window.
+func (w *SlidingWindowLimiter) Allow() bool {
+	return w.AllowN(1)
+}
+
+// AllowN admits n actions at once if the window has room for all of them. More
+// actions than the limit are never admitted.
+func (w *SlidingWindowLimiter) AllowN(n int) bool {
+	if n <= 0 {
+		return true
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.clock.Now()
+	w.pruneLocked(now)
+	if n > w.limit-len(w.log) { // Compared this way so a huge n can't overflow.
+		return false
+	}
+	for range n {
+		w.log = append(w.log, now)
+	}
+	return true
+}
+
+// Tokens returns the number of actions the window has room for right now.
+func (w *SlidingWindowLimiter) Tokens() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pruneLocked(w.clock.Now())
+	return max(w.limit-len(w.log), 0)
+}
+
+// Capacity returns the maximum number of actions in any window.
+func (w *SlidingWindowLimiter) Capacity() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.limit
+}
+
+// RetryAfter returns how long until one action could be admitted, zero if it could be
+// now, or Never if the limit is zero.
+func (w *SlidingWindowLimiter) RetryAfter() time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.limit <= 0 {
+		return Never
+	}
+	now := w.clock.Now()
+	w.pruneLocked(now)
+	if len(w.log) < w.limit {
+		return 0
+	}
+	return w.log[len(w.log)-w.limit].Add(w.window).Sub(now)
+}
+
+// SetLimit changes the limit to capacity actions per window of capacity refill
+// rates, the window NewLimiter gives a SlidingWindow limiter, which
+// RatataLimiter.SetUserLimit relies on. As in NewLimiter, the refill rate is shared
+// by the tokens per interval set with WithTokensPerInterval. If the log holds more
+// actions than the new limit, the oldest are forgotten. It returns an error, and
+// changes nothing, if the configuration is invalid.
+func (w *SlidingWindowLimiter) SetLimit(capacity int, refillRate time.Duration) error {
+	if err := validateConfig(capacity, refillRate); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.limit, w.window = capacity, time.Duration(capacity)*max(refillRate/w.perInterval, 1)
+	if extra := len(w.log) - capacity; extra > 0 {
+		w.log = append(w.log[:0], w.log[extra:]...)
+	}
+	return nil
+}
+
+// refund gives back n actions just admitted, dropping the newest entries of the log.
+func (w *SlidingWindowLimiter) refund(n int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.log = w.log[:max(len(w.log)-n, 0)]
+}
+
+// pruneLocked drops the actions that have left the window as of now. The caller must
+// hold w.mu.
+func (w *SlidingWindowLimiter) pruneLocked(now time.Time) {
+	cutoff := now.Add(-w.window)
+	i := sort.Search(len(w.log), func(i int) bool { return w.log[i].After(cutoff) })
+	if i > 0 {
+		w.log = append(w.log[:0], w.log[i:]...)
+	}
+}
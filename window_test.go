@@ -0,0 +1,46 @@
+package ratata
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSlidingWindow(t *testing.T) {
+	clock := newFakeClock()
+	l := NewSlidingWindowLimiter(3, 3*time.Second, WithClock(clock))
+	l.Allow()
+	clock.Advance(time.Second)
+	l.AllowN(2)
+
+	// The window is 3s long; the oldest action leaves it 2s from now.
+	if l.Allow() {
+		t.Error("admitted a fourth action in the window")
+	}
+	if got := l.RetryAfter(); got != 2*time.Second {
+		t.Errorf("RetryAfter = %v, want 2s", got)
+	}
+	clock.Advance(2 * time.Second)
+	if l.Tokens() != 1 || !l.Allow() || l.Allow() {
+		t.Error("want exactly one action once the oldest left the window")
+	}
+	clock.Advance(time.Second)
+	if got := l.Tokens(); got != 2 {
+		t.Errorf("Tokens = %d, want 2", got)
+	}
+	l.SetLimit(1, time.Second)
+	if got := l.Tokens(); got != 1 {
+		t.Errorf("Tokens after shrinking the limit to 1 = %d, want 1", got)
+	}
+}
+
+func TestSlidingWindowHugeCount(t *testing.T) {
+	l := NewSlidingWindowLimiter(10, time.Second, WithClock(newFakeClock()))
+	l.Allow()
+	if l.AllowN(math.MaxInt) {
+		t.Error("AllowN(math.MaxInt) was allowed by a limit of 10")
+	}
+	if got := l.Tokens(); got != 9 {
+		t.Errorf("Tokens = %d after the denial, want 9", got)
+	}
+}